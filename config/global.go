@@ -1,3 +1,11 @@
 package config
 
 var Global = &Config{}
+
+// SpoolMaxBytes 每种信号落盘的上限
+func SpoolMaxBytes() int64 {
+	if Global.SpoolMaxBytes > 0 {
+		return Global.SpoolMaxBytes
+	}
+	return 100 << 20
+}
@@ -1,12 +1,74 @@
 package config
 
+import "time"
+
 type Config struct {
 	AppName         string
 	Version         string
 	MetricsEndPoint string
 	LogEndPoint     string
+	TraceEndPoint   string // span导出的otlp grpc地址 为空时不导出
 	UseMetrics      bool
 	UseLogger       bool
 	Env             string
 	HostName        string
+	DevMode         bool                   // 本地开发模式 日志 span树和指标汇总输出到终端 不连接收集器
+	MetricViews     []MetricView           // 指标聚合配置 按顺序匹配 命中第一个生效
+	RuntimeMetrics  bool                   // 导出go runtime指标
+	ProcessMetrics  bool                   // 导出进程与容器指标 读取/proc和cgroup
+	MaxSeries       int                    // 每个指标的最大序列数 超过后新序列归入other 0不限制
+	ExemplarFilter  string                 // 直方图exemplar过滤 trace_based(默认 ctx中有采样的span时记录) always_on always_off
+	MetricsInterval time.Duration          // 指标导出间隔 默认14s
+	MetricsTimeout  time.Duration          // 指标导出超时 默认30s
+	Temporality     string                 // 时间性偏好 cumulative(默认) delta lowmemory
+	MetricsExporter string                 // 指标导出方式 otlp(默认) statsd dogstatsd(带tag)
+	StatsdAddr      string                 // statsd agent的udp地址 默认127.0.0.1:8125
+	StatsdMTU       int                    // 单个udp包的最大字节数 默认1432
+	SpoolDir        string                 // 导出失败时落盘的目录 恢复后按顺序重放 为空不启用
+	SpoolMaxBytes   int64                  // 每种信号落盘的上限 默认100MB 超过后丢弃最旧的数据
+	SemconvMetrics  bool                   // 仪表化使用otel语义约定的指标名和属性 如http.server.request.duration
+	BaggageKeys     []string               // 从ctx的baggage复制到指标 span 日志上的key
+	KindTemporality map[string]string      // 按仪表类型覆盖时间性 key: counter histogram updown gauge observable_counter observable_updown observable_gauge value: cumulative delta
+	LogxMetrics     string                 // go-zero的stat和slow日志转成指标 为空不转换 both(同时保留日志) metrics(只上报指标)
+	LogSampling     map[string]LogSampling // 日志采样 key为级别 debug info warn error 或*表示其余级别 为空不采样
+	RedactRules     []RedactRule           // 敏感数据脱敏 作用于日志 span和指标的属性 按顺序应用
+	RedactHashKey   string                 `json:"-"` // 脱敏hash使用的HMAC密钥 规则中有hash时必填 需要跨服务关联时使用相同的密钥 调试接口不展示
+	LogFile         LogFile                // 本地日志文件 Path为空不启用
+}
+
+// MetricView 单个指标的聚合配置
+type MetricView struct {
+	Name        string    // 指标名 不带AppName前缀 支持*通配 也可以匹配runtime和语义约定等本身不带前缀的指标
+	Rename      string    // 导出时使用的新名称 不带AppName前缀 Name带通配时不能设置
+	Drop        bool      // 丢弃该指标
+	Boundaries  []float64 // 直方图桶边界 为空时使用默认桶
+	Exponential bool      // 使用base2指数直方图
+	MaxSize     int32     // 指数直方图最大桶数 默认160
+	MaxScale    int32     // 指数直方图最大精度 默认20
+	AllowedKeys []string  // 属性白名单 为空时不过滤
+	MaxSeries   int       // 最大序列数 覆盖Config.MaxSeries
+}
+
+// LogSampling 日志采样配置 每个Interval内相同级别和消息的日志先输出First条 之后每Thereafter条输出一条
+type LogSampling struct {
+	Interval   time.Duration // 统计周期 默认1s
+	First      int           // 每个周期先输出的条数
+	Thereafter int           // 超过First后每多少条输出一条 0表示全部丢弃
+}
+
+// RedactRule 脱敏规则 只填Key时处理整个值 填了Pattern时处理匹配的部分
+type RedactRule struct {
+	Key     string // 属性key 不区分大小写 支持*通配 为空时匹配所有属性和日志消息
+	Pattern string // 值的正则 为空时处理整个值
+	Action  string // mask(默认 替换为***) hash(替换为HMAC-SHA256前16位 需要RedactHashKey) drop(删除整个属性)
+}
+
+// LogFile 本地日志文件配置 按大小或时间轮转
+type LogFile struct {
+	Path           string        // 文件路径 如/var/log/app/app.log
+	MaxSize        int           // 单个文件的最大MB 超过后轮转 0不按大小轮转
+	RotateInterval time.Duration // 按时间轮转的间隔 如24h 0不按时间轮转
+	MaxBackups     int           // 保留的旧文件数 0不限制
+	MaxAge         time.Duration // 旧文件保留时长 0不限制
+	Compress       bool          // 轮转后gzip压缩旧文件
 }
@@ -0,0 +1,43 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/internal/identity"
+	"github.com/watora/telemetry/metrics"
+	"github.com/watora/telemetry/trace"
+	"net/http"
+)
+
+type debugInfo struct {
+	Config      *config.Config           `json:"config"`
+	Resource    map[string]string        `json:"resource"`
+	Sampler     string                   `json:"sampler"`
+	Status      StatusInfo               `json:"status"`
+	Instruments []metrics.InstrumentInfo `json:"instruments"`
+	ActiveSpans []trace.SpanInfo         `json:"active_spans"`
+}
+
+// DebugHandler 以json展示当前的配置 resource 仪表的最新值 进行中的span和导出状态
+// 如 http.Handle("/debug/telemetry", telemetry.DebugHandler())
+func DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := debugInfo{
+			Config:      config.Global,
+			Resource:    map[string]string{},
+			Sampler:     trace.Sampler(),
+			Status:      Status(),
+			Instruments: metrics.Instruments(),
+			ActiveSpans: trace.ActiveSpans(),
+		}
+		if res, err := identity.Resource(config.Global.AppName, config.Global.Version); err == nil {
+			for _, kv := range res.Attributes() {
+				info.Resource[string(kv.Key)] = kv.Value.Emit()
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(info)
+	})
+}
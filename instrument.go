@@ -12,11 +12,14 @@ import (
 	"github.com/zeromicro/go-zero/rest"
 	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"gorm.io/gorm"
 	"net/http"
+	"strconv"
 	"strings"
-	"sync/atomic"
 	"time"
 )
 
@@ -26,21 +29,31 @@ func InstrumentGORM(db *gorm.DB) {
 		return
 	}
 	before := func(db *gorm.DB) {
-		start := time.Now().UnixMilli()
-		db.Set("metrics.start", start)
+		db.Set("metrics.start", time.Now())
 	}
 	after := func(command string) func(db *gorm.DB) {
 		return func(db *gorm.DB) {
 			if db.Statement == nil || db.Statement.Schema == nil {
 				return
 			}
-			end := time.Now().UnixMilli()
 			if v, ok := db.Get("metrics.start"); ok {
-				start := v.(int64)
+				duration := time.Since(v.(time.Time))
 				ctx := context.Background()
 				if db.Statement.Context != nil {
 					ctx = db.Statement.Context
 				}
+				if config.Global.SemconvMetrics {
+					attr := []attribute.KeyValue{
+						semconv.DBSystemKey.String(db.Dialector.Name()),
+						semconv.DBOperationNameKey.String(command),
+						semconv.DBCollectionNameKey.String(db.Statement.Table),
+					}
+					if db.Statement.Error != nil {
+						attr = append(attr, semconv.ErrorTypeKey.String(errorType(db.Statement.Error)))
+					}
+					metrics.EmitDuration(ctx, "db.client.operation.duration", duration, attr...)
+					return
+				}
 				attr := []attribute.KeyValue{
 					{Key: "table", Value: attribute.StringValue(db.Statement.Table)},
 					{Key: "success", Value: attribute.BoolValue(db.Statement.Error == nil)},
@@ -50,7 +63,7 @@ func InstrumentGORM(db *gorm.DB) {
 					{Key: "driver", Value: attribute.StringValue(db.Dialector.Name())},
 					{Key: "version", Value: attribute.StringValue(config.Global.Version)},
 				}
-				metrics.EmitTime(ctx, "gorm_duration", end-start, attr...)
+				metrics.EmitTime(ctx, "gorm_duration", duration.Milliseconds(), attr...)
 				metrics.EmitCount(ctx, "gorm_count", 1, attr...)
 			}
 		}
@@ -74,9 +87,11 @@ func InstrumentGoZero(server *rest.Server) {
 	//add middleware
 	server.Use(func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now().UnixMilli()
+			start := time.Now()
 			wl := &metrics.WriteLogger{ResponseWriter: w}
-			newCtx, span := trace.StartTrace(r.Context(), "http_request")
+			// 从header中取上游的trace和baggage
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			newCtx, span := trace.StartTrace(ctx, "http_request")
 			defer span.End()
 			r = r.WithContext(newCtx)
 			next(wl, r)
@@ -84,7 +99,27 @@ func InstrumentGoZero(server *rest.Server) {
 				strings.HasPrefix(r.URL.Path, "/metrics") {
 				return
 			}
-			end := time.Now().UnixMilli()
+			if config.Global.SemconvMetrics {
+				// 没有调用WriteHeader时为200
+				status := wl.StatusCode
+				if status == 0 {
+					status = http.StatusOK
+				}
+				scheme := "http"
+				if r.TLS != nil {
+					scheme = "https"
+				}
+				attr := []attribute.KeyValue{
+					semconv.HTTPRequestMethodKey.String(r.Method),
+					semconv.HTTPResponseStatusCodeKey.Int(status),
+					semconv.URLSchemeKey.String(scheme),
+				}
+				if status >= 500 {
+					attr = append(attr, semconv.ErrorTypeKey.String(strconv.Itoa(status)))
+				}
+				metrics.EmitDuration(newCtx, "http.server.request.duration", time.Since(start), attr...)
+				return
+			}
 			attr := []attribute.KeyValue{
 				{Key: "path", Value: attribute.StringValue(r.URL.Path)},
 				{Key: "method", Value: attribute.StringValue(r.Method)},
@@ -94,7 +129,7 @@ func InstrumentGoZero(server *rest.Server) {
 				{Key: "env", Value: attribute.StringValue(config.Global.Env)},
 				{Key: "version", Value: attribute.StringValue(config.Global.Version)},
 			}
-			metrics.EmitTime(newCtx, "http_duration", end-start, attr...)
+			metrics.EmitTime(newCtx, "http_duration", time.Since(start).Milliseconds(), attr...)
 			metrics.EmitCount(newCtx, "http_count", 1, attr...)
 		}
 	})
@@ -120,15 +155,13 @@ func InstrumentZinx(server ziface.IServer) {
 		metrics.EmitCount(ctx, "zinx_count", 1, attr...)
 	})
 	// 记录连接数
-	var connected int64
 	server.SetOnConnStart(func(connection ziface.IConnection) {
 		attr := []attribute.KeyValue{
 			{Key: "host", Value: attribute.StringValue(config.Global.HostName)},
 			{Key: "env", Value: attribute.StringValue(config.Global.Env)},
 			{Key: "version", Value: attribute.StringValue(config.Global.Version)},
 		}
-		atomic.AddInt64(&connected, 1)
-		metrics.EmitGauge(connection.Context(), "zinx_live", atomic.LoadInt64(&connected), attr...)
+		metrics.EmitUpDown(connection.Context(), "zinx_live", 1, attr...)
 	})
 	server.SetOnConnStop(func(connection ziface.IConnection) {
 		attr := []attribute.KeyValue{
@@ -136,8 +169,7 @@ func InstrumentZinx(server ziface.IServer) {
 			{Key: "env", Value: attribute.StringValue(config.Global.Env)},
 			{Key: "version", Value: attribute.StringValue(config.Global.Version)},
 		}
-		atomic.AddInt64(&connected, -1)
-		metrics.EmitGauge(connection.Context(), "zinx_live", atomic.LoadInt64(&connected), attr...)
+		metrics.EmitUpDown(connection.Context(), "zinx_live", -1, attr...)
 	})
 }
 
@@ -148,11 +180,10 @@ func InstrumentRedisV8(client *redis.ClusterClient) {
 	}
 	client.AddHook(&metrics.RedisHook{
 		MeterBefore: func(ctx context.Context) context.Context {
-			start := time.Now().UnixMilli()
 			if ctx == nil {
 				ctx = context.Background()
 			}
-			return context.WithValue(ctx, "metrics.before", start)
+			return context.WithValue(ctx, "metrics.before", time.Now())
 		},
 		MeterAfter: func(ctx context.Context, cmd string) {
 			if ctx == nil {
@@ -162,14 +193,19 @@ func InstrumentRedisV8(client *redis.ClusterClient) {
 			if start == nil {
 				return
 			}
-			end := time.Now().UnixMilli()
+			duration := time.Since(start.(time.Time))
+			if config.Global.SemconvMetrics {
+				metrics.EmitDuration(ctx, "db.client.operation.duration", duration,
+					semconv.DBSystemRedis, semconv.DBOperationNameKey.String(cmd))
+				return
+			}
 			attr := []attribute.KeyValue{
 				{Key: "cmd", Value: attribute.StringValue(cmd)},
 				{Key: "host", Value: attribute.StringValue(config.Global.HostName)},
 				{Key: "env", Value: attribute.StringValue(config.Global.Env)},
 				{Key: "version", Value: attribute.StringValue(config.Global.Version)},
 			}
-			metrics.EmitTime(ctx, "redis_v8_duration", end-start.(int64), attr...)
+			metrics.EmitTime(ctx, "redis_v8_duration", duration.Milliseconds(), attr...)
 			metrics.EmitCount(ctx, "redis_v8_count", 1, attr...)
 		},
 	})
@@ -183,8 +219,12 @@ func InstrumentRedis(client *redisV6.ClusterClient) {
 	// 替换process
 	client.WrapProcess(func(oldProcess func(redisV6.Cmder) error) func(redisV6.Cmder) error {
 		return func(cmder redisV6.Cmder) error {
-			start := time.Now().UnixMilli()
+			start := time.Now()
 			err := oldProcess(cmder)
+			if config.Global.SemconvMetrics {
+				emitRedisSemconv(cmder.Name(), time.Since(start), err)
+				return err
+			}
 			attr := []attribute.KeyValue{
 				{Key: "cmd", Value: attribute.StringValue(cmder.Name())},
 				{Key: "host", Value: attribute.StringValue(config.Global.HostName)},
@@ -192,7 +232,7 @@ func InstrumentRedis(client *redisV6.ClusterClient) {
 				{Key: "version", Value: attribute.StringValue(config.Global.Version)},
 			}
 			ctx := context.Background()
-			metrics.EmitTime(ctx, "redis_v6_duration", time.Now().UnixMilli()-start, attr...)
+			metrics.EmitTime(ctx, "redis_v6_duration", time.Since(start).Milliseconds(), attr...)
 			metrics.EmitCount(ctx, "redis_v6_count", 1, attr...)
 			return err
 		}
@@ -200,8 +240,12 @@ func InstrumentRedis(client *redisV6.ClusterClient) {
 	// pipeline
 	client.WrapProcessPipeline(func(oldProcess func([]redisV6.Cmder) error) func([]redisV6.Cmder) error {
 		return func(cmders []redisV6.Cmder) error {
-			start := time.Now().UnixMilli()
+			start := time.Now()
 			err := oldProcess(cmders)
+			if config.Global.SemconvMetrics {
+				emitRedisSemconv("pipeline", time.Since(start), err)
+				return err
+			}
 			attr := []attribute.KeyValue{
 				{Key: "cmd", Value: attribute.StringValue("pipeline")},
 				{Key: "host", Value: attribute.StringValue(config.Global.HostName)},
@@ -209,25 +253,49 @@ func InstrumentRedis(client *redisV6.ClusterClient) {
 				{Key: "version", Value: attribute.StringValue(config.Global.Version)},
 			}
 			ctx := context.Background()
-			metrics.EmitTime(ctx, "redis_v6_duration", time.Now().UnixMilli()-start, attr...)
+			metrics.EmitTime(ctx, "redis_v6_duration", time.Since(start).Milliseconds(), attr...)
 			metrics.EmitCount(ctx, "redis_v6_count", 1, attr...)
 			return err
 		}
 	})
 }
 
+func emitRedisSemconv(cmd string, duration time.Duration, err error) {
+	attr := []attribute.KeyValue{
+		semconv.DBSystemRedis,
+		semconv.DBOperationNameKey.String(cmd),
+	}
+	// redis.Nil表示key不存在 不算错误
+	if err != nil && err != redisV6.Nil {
+		attr = append(attr, semconv.ErrorTypeKey.String(errorType(err)))
+	}
+	metrics.EmitDuration(context.Background(), "db.client.operation.duration", duration, attr...)
+}
+
 // InstrumentMongo 仪表化mongo
 func InstrumentMongo(options *options.ClientOptions) *options.ClientOptions {
 	if !config.Global.UseMetrics {
 		return options
 	}
 	emit := func(ctx context.Context, command string, success bool, duration time.Duration) {
+		if config.Global.SemconvMetrics {
+			attr := []attribute.KeyValue{
+				semconv.DBSystemMongoDB,
+				semconv.DBOperationNameKey.String(command),
+			}
+			if !success {
+				attr = append(attr, semconv.ErrorTypeKey.String("_OTHER"))
+			}
+			metrics.EmitDuration(ctx, "db.client.operation.duration", duration, attr...)
+			return
+		}
 		attr := []attribute.KeyValue{
 			{Key: "cmd", Value: attribute.StringValue(command)},
 			{Key: "host", Value: attribute.StringValue(config.Global.HostName)},
 			{Key: "env", Value: attribute.StringValue(config.Global.Env)},
 			{Key: "version", Value: attribute.StringValue(config.Global.Version)},
-			{Key: "success", Value: attribute.BoolValue(success)},
+			// 旧指标保持原来的行为 失败的命令也上报success=true 避免已有的序列变化
+			{Key: "success", Value: attribute.BoolValue(true)},
 		}
 		metrics.EmitTime(ctx, "mongo_duration", duration.Milliseconds(), attr...)
 		metrics.EmitCount(ctx, "mongo_count", 1, attr...)
@@ -237,8 +305,13 @@ func InstrumentMongo(options *options.ClientOptions) *options.ClientOptions {
 			emit(ctx, succeededEvent.CommandName, true, succeededEvent.Duration)
 		},
 		Failed: func(ctx context.Context, failedEvent *event.CommandFailedEvent) {
-			emit(ctx, failedEvent.CommandName, true, failedEvent.Duration)
+			emit(ctx, failedEvent.CommandName, false, failedEvent.Duration)
 		},
 	}
 	return options.SetMonitor(monitor)
 }
+
+// 语义约定中error.type取错误的类型名
+func errorType(err error) string {
+	return fmt.Sprintf("%T", err)
+}
@@ -0,0 +1,36 @@
+// Package console 开发模式下终端输出的颜色
+package console
+
+const (
+	reset  = "\x1b[0m"
+	bold   = "\x1b[1m"
+	dim    = "\x1b[2m"
+	red    = "\x1b[31m"
+	green  = "\x1b[32m"
+	yellow = "\x1b[33m"
+	cyan   = "\x1b[36m"
+)
+
+func Bold(s string) string {
+	return bold + s + reset
+}
+
+func Dim(s string) string {
+	return dim + s + reset
+}
+
+func Red(s string) string {
+	return red + s + reset
+}
+
+func Green(s string) string {
+	return green + s + reset
+}
+
+func Yellow(s string) string {
+	return yellow + s + reset
+}
+
+func Cyan(s string) string {
+	return cyan + s + reset
+}
@@ -0,0 +1,133 @@
+// Package health 记录各个信号的导出情况
+package health
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	Logs    = "logs"
+	Metrics = "metrics"
+	Spans   = "spans"
+)
+
+// SignalStatus 单个信号的导出情况
+type SignalStatus struct {
+	LastSuccess   time.Time `json:"last_success"`
+	LastFailure   time.Time `json:"last_failure"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastSpooled   time.Time `json:"last_spooled"`
+	Exported      int64     `json:"exported"`       // 导出成功的条数
+	Failed        int64     `json:"failed"`         // 导出失败的条数
+	Dropped       int64     `json:"dropped"`        // 丢弃的条数
+	Spooled       int64     `json:"spooled"`        // 导出失败后落盘的条数 重放成功后计入Exported
+	QueueSize     int64     `json:"queue_size"`     // 落盘待重放的条数
+	ExportSuccess int64     `json:"export_success"` // 导出成功的次数
+	ExportFailure int64     `json:"export_failure"` // 导出失败的次数
+}
+
+var mu sync.Mutex
+var signals = map[string]*SignalStatus{
+	Logs:    {},
+	Metrics: {},
+	Spans:   {},
+}
+var queues = map[string]func() (size int64, dropped int64){}
+var lastError string
+var lastErrorTime time.Time
+var errorCount int64
+
+// Export 记录一次导出 只有收集器确实收到数据时才传入nil 包括落盘数据的重放
+func Export(signal string, n int, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	s := signals[signal]
+	if err == nil {
+		s.LastSuccess = time.Now()
+		s.Exported += int64(n)
+		s.ExportSuccess++
+		return
+	}
+	s.LastFailure = time.Now()
+	s.LastError = err.Error()
+	s.Failed += int64(n)
+	s.ExportFailure++
+}
+
+// Spool 记录导出失败后落盘的数据 收集器没有收到 不算导出成功
+func Spool(signal string, n int) {
+	mu.Lock()
+	defer mu.Unlock()
+	s := signals[signal]
+	s.LastSpooled = time.Now()
+	s.Spooled += int64(n)
+}
+
+// Drop 记录丢弃的数据
+func Drop(signal string, n int) {
+	mu.Lock()
+	defer mu.Unlock()
+	signals[signal].Dropped += int64(n)
+}
+
+// SetQueue 注册信号的队列 返回待处理的条数和队列自身丢弃的条数
+func SetQueue(signal string, fn func() (size int64, dropped int64)) {
+	mu.Lock()
+	defer mu.Unlock()
+	queues[signal] = fn
+}
+
+// Snapshot 所有信号的导出情况
+func Snapshot() map[string]SignalStatus {
+	mu.Lock()
+	defer mu.Unlock()
+	res := make(map[string]SignalStatus, len(signals))
+	for name, s := range signals {
+		status := *s
+		if fn, ok := queues[name]; ok {
+			size, dropped := fn()
+			status.QueueSize = size
+			status.Dropped += dropped
+		}
+		res[name] = status
+	}
+	return res
+}
+
+// Errors otel上报的错误次数和最后一个错误
+func Errors() (count int64, last string, at time.Time) {
+	mu.Lock()
+	defer mu.Unlock()
+	return errorCount, lastError, lastErrorTime
+}
+
+// ErrorHandler 替换otel默认的错误处理 记录错误 相同的错误每分钟最多输出一次
+type ErrorHandler struct {
+	mu      sync.Mutex
+	printed map[string]time.Time
+}
+
+func (h *ErrorHandler) Handle(err error) {
+	if err == nil {
+		return
+	}
+	msg := err.Error()
+	mu.Lock()
+	errorCount++
+	lastError = msg
+	lastErrorTime = time.Now()
+	mu.Unlock()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.printed == nil || len(h.printed) > 1000 {
+		h.printed = make(map[string]time.Time)
+	}
+	if t, ok := h.printed[msg]; ok && time.Since(t) < time.Minute {
+		return
+	}
+	h.printed[msg] = time.Now()
+	log.Printf("telemetry: %v", msg)
+}
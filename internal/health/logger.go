@@ -0,0 +1,99 @@
+package health
+
+import (
+	"github.com/go-logr/logr"
+	"sync"
+)
+
+// sdk批处理队列满时丢弃数据 只在otel内部日志中输出 这里从日志中取出丢弃的条数
+const (
+	droppedLogsMessage = "dropped log records" // sdk/log BatchProcessor 带本次丢弃的条数dropped
+	exportSpansMessage = "exporting spans"     // sdktrace BatchSpanProcessor 带累计丢弃的条数total_dropped
+)
+
+var spansMu sync.Mutex
+var spansDropped uint64
+
+// Logger 包装otel的内部logger 统计批处理队列丢弃的日志和span 其余日志照常交给next输出
+func Logger(next logr.Logger) logr.Logger {
+	sink := next.GetSink()
+	// 多了一层调用 保证stdr输出的调用位置正确
+	if cd, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = cd.WithCallDepth(1)
+	}
+	return logr.New(&dropSink{next: sink})
+}
+
+type dropSink struct {
+	next logr.LogSink
+}
+
+func (s *dropSink) Init(info logr.RuntimeInfo) {
+	s.next.Init(info)
+}
+
+// debug级别的日志也需要收到 输出时再按next的级别过滤
+func (s *dropSink) Enabled(level int) bool {
+	return true
+}
+
+func (s *dropSink) Info(level int, msg string, keysAndValues ...any) {
+	switch msg {
+	case droppedLogsMessage:
+		if n, ok := intValue(keysAndValues, "dropped"); ok && n > 0 {
+			Drop(Logs, int(n))
+		}
+	case exportSpansMessage:
+		if total, ok := intValue(keysAndValues, "total_dropped"); ok {
+			dropSpans(total)
+		}
+	}
+	if s.next.Enabled(level) {
+		s.next.Info(level, msg, keysAndValues...)
+	}
+}
+
+func (s *dropSink) Error(err error, msg string, keysAndValues ...any) {
+	s.next.Error(err, msg, keysAndValues...)
+}
+
+func (s *dropSink) WithValues(keysAndValues ...any) logr.LogSink {
+	return &dropSink{next: s.next.WithValues(keysAndValues...)}
+}
+
+func (s *dropSink) WithName(name string) logr.LogSink {
+	return &dropSink{next: s.next.WithName(name)}
+}
+
+// total_dropped是processor的累计值 只记录增量 变小说明换了新的processor
+func dropSpans(total uint64) {
+	spansMu.Lock()
+	delta := total
+	if total >= spansDropped {
+		delta = total - spansDropped
+	}
+	spansDropped = total
+	spansMu.Unlock()
+	if delta > 0 {
+		Drop(Spans, int(delta))
+	}
+}
+
+func intValue(keysAndValues []any, key string) (uint64, bool) {
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if keysAndValues[i] != key {
+			continue
+		}
+		switch v := keysAndValues[i+1].(type) {
+		case uint64:
+			return v, true
+		case uint32:
+			return uint64(v), true
+		case int:
+			return uint64(v), v >= 0
+		case int64:
+			return uint64(v), v >= 0
+		}
+	}
+	return 0, false
+}
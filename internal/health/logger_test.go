@@ -0,0 +1,42 @@
+package health
+
+import (
+	"github.com/go-logr/stdr"
+	"io"
+	"log"
+	"testing"
+)
+
+func dropped(signal string) int64 {
+	return Snapshot()[signal].Dropped
+}
+
+func TestLoggerCountsDrops(t *testing.T) {
+	l := Logger(stdr.New(log.New(io.Discard, "", 0))).WithName("otel")
+	logs, spans := dropped(Logs), dropped(Spans)
+
+	// 和sdk中的调用一致 Warn是V(1) Debug是V(8)
+	l.V(1).Info("dropped log records", "dropped", uint64(3))
+	l.V(1).Info("dropped log records", "dropped", uint64(2))
+	if got := dropped(Logs) - logs; got != 5 {
+		t.Errorf("dropped logs = %v, want 5", got)
+	}
+
+	// total_dropped是累计值
+	l.V(8).Info("exporting spans", "count", 10, "total_dropped", uint32(4))
+	l.V(8).Info("exporting spans", "count", 10, "total_dropped", uint32(4))
+	l.V(8).Info("exporting spans", "count", 10, "total_dropped", uint32(7))
+	if got := dropped(Spans) - spans; got != 7 {
+		t.Errorf("dropped spans = %v, want 7", got)
+	}
+	// 换了新的processor 计数重新开始
+	l.V(8).Info("exporting spans", "count", 10, "total_dropped", uint32(2))
+	if got := dropped(Spans) - spans; got != 9 {
+		t.Errorf("dropped spans = %v, want 9", got)
+	}
+
+	l.V(1).Info("other message", "dropped", uint64(100))
+	if got := dropped(Logs) - logs; got != 5 {
+		t.Errorf("dropped logs = %v, want 5", got)
+	}
+}
@@ -0,0 +1,23 @@
+package identity
+
+import (
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"os"
+)
+
+// Resource 服务标识 日志和指标共用
+func Resource(appName string, version string) (*resource.Resource, error) {
+	hostName, _ := os.Hostname()
+	// 新建resource
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(appName),
+			semconv.ServiceVersion(version),
+			semconv.ServiceInstanceID(hostName),
+		))
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
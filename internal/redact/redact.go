@@ -0,0 +1,240 @@
+// Package redact 对日志 span和指标中的敏感数据脱敏
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/watora/telemetry/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"path"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	ActionMask = "mask"
+	ActionHash = "hash"
+	ActionDrop = "drop"
+)
+
+const maskText = "***"
+
+type rule struct {
+	key     string // 小写
+	pattern *regexp.Regexp
+	action  string
+}
+
+// Redactor 编译后的脱敏规则 nil表示不处理
+type Redactor struct {
+	rules   []rule
+	hashKey []byte
+}
+
+var current atomic.Pointer[Redactor]
+
+// Setup 编译配置中的规则并替换当前生效的规则
+func Setup(rules []config.RedactRule, hashKey string) error {
+	r, err := New(rules, hashKey)
+	if err != nil {
+		return err
+	}
+	current.Store(r)
+	return nil
+}
+
+// Current 当前生效的规则 没有配置时为nil
+func Current() *Redactor {
+	return current.Load()
+}
+
+// New 编译规则 没有规则时返回nil 使用hash时必须提供hashKey
+func New(rules []config.RedactRule, hashKey string) (*Redactor, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	r := &Redactor{hashKey: []byte(hashKey)}
+	for _, item := range rules {
+		if item.Key == "" && item.Pattern == "" {
+			return nil, fmt.Errorf("redact rule needs a key or a pattern: %+v", item)
+		}
+		action := strings.ToLower(item.Action)
+		switch action {
+		case "":
+			action = ActionMask
+		case ActionMask, ActionDrop:
+		case ActionHash:
+			// 不加密钥的短hash可以直接穷举手机号 身份证号等取值范围小的数据
+			if hashKey == "" {
+				return nil, fmt.Errorf("redact action hash needs RedactHashKey: %+v", item)
+			}
+		default:
+			return nil, fmt.Errorf("unknown redact action %v", item.Action)
+		}
+		key := strings.ToLower(item.Key)
+		if _, err := path.Match(key, ""); err != nil {
+			return nil, fmt.Errorf("invalid redact key %v: %w", item.Key, err)
+		}
+		rl := rule{key: key, action: action}
+		if item.Pattern != "" {
+			pattern, err := regexp.Compile(item.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid redact pattern %v: %w", item.Pattern, err)
+			}
+			rl.pattern = pattern
+		}
+		r.rules = append(r.rules, rl)
+	}
+	return r, nil
+}
+
+func (rl rule) matchKey(key string) bool {
+	if rl.key == "" {
+		return true
+	}
+	matched, _ := path.Match(rl.key, strings.ToLower(key))
+	return matched
+}
+
+// hash使用HMAC-SHA256 相同密钥下相同的值结果一致 可以用于关联
+func (r *Redactor) apply(action string, s string) string {
+	if action == ActionHash {
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(s))
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil))[:16]
+	}
+	return maskText
+}
+
+// String 处理一个属性值 返回false表示删除该属性
+func (r *Redactor) String(key string, value string) (string, bool) {
+	if r == nil {
+		return value, true
+	}
+	for _, rl := range r.rules {
+		if !rl.matchKey(key) {
+			continue
+		}
+		if rl.pattern == nil {
+			if rl.action == ActionDrop {
+				return "", false
+			}
+			return r.apply(rl.action, value), true
+		}
+		if !rl.pattern.MatchString(value) {
+			continue
+		}
+		if rl.action == ActionDrop {
+			return "", false
+		}
+		value = rl.pattern.ReplaceAllStringFunc(value, func(s string) string {
+			return r.apply(rl.action, s)
+		})
+	}
+	return value, true
+}
+
+// Message 处理日志消息 只应用没有key的规则 drop时整条消息替换为***
+func (r *Redactor) Message(message string) string {
+	if r == nil {
+		return message
+	}
+	for _, rl := range r.rules {
+		if rl.key != "" || !rl.pattern.MatchString(message) {
+			continue
+		}
+		if rl.action == ActionDrop {
+			return maskText
+		}
+		message = rl.pattern.ReplaceAllStringFunc(message, func(s string) string {
+			return r.apply(rl.action, s)
+		})
+	}
+	return message
+}
+
+// Attributes 处理span和指标的属性 非字符串的值按字符串形式匹配 命中后替换为字符串
+func (r *Redactor) Attributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	if r == nil {
+		return attrs
+	}
+	result := make([]attribute.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		if kv.Value.Type() == attribute.STRINGSLICE {
+			values := kv.Value.AsStringSlice()
+			redacted := make([]string, 0, len(values))
+			dropped := false
+			for _, v := range values {
+				s, ok := r.String(string(kv.Key), v)
+				if !ok {
+					dropped = true
+					break
+				}
+				redacted = append(redacted, s)
+			}
+			if !dropped {
+				result = append(result, kv.Key.StringSlice(redacted))
+			}
+			continue
+		}
+		value := kv.Value.Emit()
+		s, ok := r.String(string(kv.Key), value)
+		if !ok {
+			continue
+		}
+		if s != value {
+			kv = kv.Key.String(s)
+		}
+		result = append(result, kv)
+	}
+	return result
+}
+
+// LogValue 处理otel日志的值 map按子key递归处理
+func (r *Redactor) LogValue(key string, value log.Value) (log.Value, bool) {
+	if r == nil {
+		return value, true
+	}
+	switch value.Kind() {
+	case log.KindMap:
+		kvs := make([]log.KeyValue, 0, len(value.AsMap()))
+		for _, kv := range value.AsMap() {
+			if v, ok := r.LogValue(kv.Key, kv.Value); ok {
+				kvs = append(kvs, log.KeyValue{Key: kv.Key, Value: v})
+			}
+		}
+		return log.MapValue(kvs...), true
+	case log.KindSlice:
+		values := make([]log.Value, 0, len(value.AsSlice()))
+		for _, v := range value.AsSlice() {
+			v, ok := r.LogValue(key, v)
+			if !ok {
+				return log.Value{}, false
+			}
+			values = append(values, v)
+		}
+		return log.SliceValue(values...), true
+	case log.KindEmpty:
+		return value, true
+	case log.KindString:
+		s, ok := r.String(key, value.AsString())
+		if !ok {
+			return log.Value{}, false
+		}
+		return log.StringValue(s), true
+	default:
+		original := value.String()
+		s, ok := r.String(key, original)
+		if !ok {
+			return log.Value{}, false
+		}
+		if s != original {
+			return log.StringValue(s), true
+		}
+		return value, true
+	}
+}
@@ -0,0 +1,248 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/watora/telemetry/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"reflect"
+	"testing"
+)
+
+const testKey = "secret"
+
+func hashOf(key string, s string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(s))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+func mustNew(t *testing.T, rules ...config.RedactRule) *Redactor {
+	t.Helper()
+	r, err := New(rules, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []config.RedactRule
+		hashKey string
+		wantErr bool
+	}{
+		{"no rules", nil, "", false},
+		{"default action", []config.RedactRule{{Key: "token"}}, "", false},
+		{"action case insensitive", []config.RedactRule{{Key: "token", Action: "DROP"}}, "", false},
+		{"hash with key", []config.RedactRule{{Key: "id", Action: "hash"}}, testKey, false},
+		{"hash without key", []config.RedactRule{{Key: "id", Action: "hash"}}, "", true},
+		{"empty rule", []config.RedactRule{{Action: "mask"}}, "", true},
+		{"unknown action", []config.RedactRule{{Key: "token", Action: "encrypt"}}, "", true},
+		{"invalid key", []config.RedactRule{{Key: "[token"}}, "", true},
+		{"invalid pattern", []config.RedactRule{{Pattern: "("}}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.rules, tt.hashKey)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  []config.RedactRule
+		key    string
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{"mask by key", []config.RedactRule{{Key: "token"}}, "token", "abc", "***", true},
+		{"key case insensitive", []config.RedactRule{{Key: "Token"}}, "TOKEN", "abc", "***", true},
+		{"key glob", []config.RedactRule{{Key: "id_*"}}, "id_card", "123", "***", true},
+		{"key glob no match", []config.RedactRule{{Key: "id_*"}}, "user_id", "123", "123", true},
+		{"drop by key", []config.RedactRule{{Key: "password", Action: "drop"}}, "password", "p", "", false},
+		{"hash by key", []config.RedactRule{{Key: "id", Action: "hash"}}, "id", "42", hashOf(testKey, "42"), true},
+		{"pattern mask", []config.RedactRule{{Pattern: `1[3-9]\d{9}`}}, "msg", "call 13800138000 now", "call *** now", true},
+		{"pattern hash", []config.RedactRule{{Pattern: `\d+`, Action: "hash"}}, "msg", "a1b22", "a" + hashOf(testKey, "1") + "b" + hashOf(testKey, "22"), true},
+		{"pattern no match", []config.RedactRule{{Pattern: `\d+`}}, "msg", "abc", "abc", true},
+		{"pattern drop", []config.RedactRule{{Pattern: `\d+`, Action: "drop"}}, "msg", "a1", "", false},
+		{"pattern drop no match", []config.RedactRule{{Pattern: `\d+`, Action: "drop"}}, "msg", "a", "a", true},
+		{"key and pattern", []config.RedactRule{{Key: "email", Pattern: `@.*`}}, "email", "a@b.com", "a***", true},
+		{"key and pattern other key", []config.RedactRule{{Key: "email", Pattern: `@.*`}}, "name", "a@b.com", "a@b.com", true},
+		{
+			"rules in order",
+			[]config.RedactRule{{Pattern: `\d`}, {Key: "token", Action: "drop"}},
+			"token", "1", "", false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mustNew(t, tt.rules...).String(tt.key, tt.value)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("String(%q, %q) = %q, %v, want %q, %v", tt.key, tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	rules := []config.RedactRule{{Key: "id", Action: "hash"}}
+	a, _ := New(rules, "a")
+	b, _ := New(rules, "b")
+	first, _ := a.String("id", "42")
+	second, _ := a.String("id", "42")
+	other, _ := b.String("id", "42")
+	if first != second {
+		t.Errorf("hash not stable: %v %v", first, second)
+	}
+	if first == other {
+		t.Errorf("hash does not depend on the key: %v", first)
+	}
+}
+
+func TestNilRedactor(t *testing.T) {
+	var r *Redactor
+	if s, ok := r.String("token", "abc"); s != "abc" || !ok {
+		t.Errorf("String = %q, %v", s, ok)
+	}
+	if m := r.Message("13800138000"); m != "13800138000" {
+		t.Errorf("Message = %q", m)
+	}
+	attrs := []attribute.KeyValue{attribute.String("token", "abc")}
+	if got := r.Attributes(attrs); !reflect.DeepEqual(got, attrs) {
+		t.Errorf("Attributes = %v", got)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []config.RedactRule
+		in    string
+		want  string
+	}{
+		{"pattern mask", []config.RedactRule{{Pattern: `1[3-9]\d{9}`}}, "phone 13800138000", "phone ***"},
+		{"pattern hash", []config.RedactRule{{Pattern: `\d{3}`, Action: "hash"}}, "id 123", "id " + hashOf(testKey, "123")},
+		{"pattern drop", []config.RedactRule{{Pattern: `secret`, Action: "drop"}}, "my secret", "***"},
+		{"key rules ignored", []config.RedactRule{{Key: "token"}, {Key: "msg", Pattern: `\d`}}, "token 1", "token 1"},
+		{"no match", []config.RedactRule{{Pattern: `\d`}}, "hello", "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mustNew(t, tt.rules...).Message(tt.in); got != tt.want {
+				t.Errorf("Message(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	r := mustNew(t,
+		config.RedactRule{Key: "token"},
+		config.RedactRule{Key: "password", Action: "drop"},
+		config.RedactRule{Key: "id", Action: "hash"},
+		config.RedactRule{Key: "tags", Pattern: `secret`},
+		config.RedactRule{Key: "blocked", Pattern: `x`, Action: "drop"},
+	)
+	got := r.Attributes([]attribute.KeyValue{
+		attribute.String("token", "abc"),
+		attribute.String("password", "p"),
+		attribute.Int("id", 42),
+		attribute.Bool("ok", true),
+		attribute.StringSlice("tags", []string{"a", "secret", "b-secret"}),
+		attribute.StringSlice("blocked", []string{"a", "x"}),
+		attribute.Int("count", 3),
+	})
+	want := []attribute.KeyValue{
+		attribute.String("token", "***"),
+		attribute.String("id", hashOf(testKey, "42")),
+		attribute.Bool("ok", true),
+		attribute.StringSlice("tags", []string{"a", "***", "b-***"}),
+		attribute.Int("count", 3),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Attributes =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestLogValue(t *testing.T) {
+	r := mustNew(t,
+		config.RedactRule{Key: "token"},
+		config.RedactRule{Key: "password", Action: "drop"},
+		config.RedactRule{Key: "id", Action: "hash"},
+		config.RedactRule{Pattern: `1[3-9]\d{9}`},
+	)
+	tests := []struct {
+		name   string
+		key    string
+		in     log.Value
+		want   log.Value
+		wantOK bool
+	}{
+		{"string", "token", log.StringValue("abc"), log.StringValue("***"), true},
+		{"drop", "password", log.StringValue("p"), log.Value{}, false},
+		{"int hashed", "id", log.IntValue(42), log.StringValue(hashOf(testKey, "42")), true},
+		{"int untouched", "count", log.IntValue(3), log.IntValue(3), true},
+		{"empty", "token", log.Value{}, log.Value{}, true},
+		{
+			"map by sub key",
+			"user",
+			log.MapValue(
+				log.String("name", "n"),
+				log.String("token", "abc"),
+				log.String("password", "p"),
+				log.Map("contact", log.String("phone", "13800138000")),
+			),
+			log.MapValue(
+				log.String("name", "n"),
+				log.String("token", "***"),
+				log.Map("contact", log.String("phone", "***")),
+			),
+			true,
+		},
+		{
+			"slice",
+			"token",
+			log.SliceValue(log.StringValue("a"), log.StringValue("b")),
+			log.SliceValue(log.StringValue("***"), log.StringValue("***")),
+			true,
+		},
+		{"slice drop", "password", log.SliceValue(log.StringValue("a")), log.Value{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := r.LogValue(tt.key, tt.in)
+			if !got.Equal(tt.want) || ok != tt.wantOK {
+				t.Errorf("LogValue(%v) = %v, %v, want %v, %v", tt.key, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSetup(t *testing.T) {
+	defer current.Store(nil)
+	if err := Setup([]config.RedactRule{{Key: "token"}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := Current().String("token", "abc"); s != "***" {
+		t.Errorf("Current not updated: %v", s)
+	}
+	// 失败时保留原来的规则
+	if err := Setup([]config.RedactRule{{Key: "id", Action: "hash"}}, ""); err == nil {
+		t.Fatal("expected error for hash without key")
+	}
+	if Current() == nil {
+		t.Errorf("failed Setup replaced the current rules")
+	}
+	if err := Setup(nil, ""); err != nil || Current() != nil {
+		t.Errorf("Setup(nil) = %v, current %v", err, Current())
+	}
+}
@@ -0,0 +1,32 @@
+// Package setup telemetry.Init和telemetrytest.Init共用的初始化
+package setup
+
+import (
+	"fmt"
+	"github.com/go-logr/stdr"
+	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/internal/health"
+	"github.com/watora/telemetry/internal/redact"
+	"go.opentelemetry.io/otel"
+	stdlog "log"
+	"os"
+	"strings"
+)
+
+// Prepare 填充默认配置后调用fn修改 再注册错误处理和脱敏规则 之后再初始化各个信号
+func Prepare(fn func(cfg *config.Config)) *config.Config {
+	cfg := config.Global
+	cfg.HostName, _ = os.Hostname()
+	if fn != nil {
+		fn(cfg)
+	}
+	cfg.AppName = strings.ReplaceAll(cfg.AppName, "-", "_")
+	// 记录导出错误 供Status和自监控指标使用
+	otel.SetErrorHandler(&health.ErrorHandler{})
+	// 和otel默认的内部logger一样输出到stderr 同时统计批处理队列丢弃的日志和span
+	otel.SetLogger(health.Logger(stdr.New(stdlog.New(os.Stderr, "", stdlog.LstdFlags|stdlog.Lshortfile))))
+	if err := redact.Setup(cfg.RedactRules, cfg.RedactHashKey); err != nil {
+		panic(fmt.Sprintf("init redaction: %v", err))
+	}
+	return cfg
+}
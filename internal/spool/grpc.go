@@ -0,0 +1,73 @@
+package spool
+
+import (
+	"context"
+	"github.com/watora/telemetry/internal/health"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Interceptor otlp grpc exporter的拦截器 导出失败时把请求落盘 恢复后先按顺序重放落盘的数据再导出当前数据
+// 收集器收到数据(包括重放)时记为导出成功 落盘的只记为spooled count返回请求中的条数
+func Interceptor(s *Spool, signal string, count func(req proto.Message) int) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		err := s.Drain(func(data []byte) error {
+			spooled := msg.ProtoReflect().New().Interface()
+			if err := proto.Unmarshal(data, spooled); err != nil {
+				s.Drop()
+				return nil
+			}
+			var spooledReply any = reply
+			if r, ok := reply.(proto.Message); ok {
+				spooledReply = r.ProtoReflect().New().Interface()
+			}
+			err := invoker(ctx, method, spooled, spooledReply, cc, opts...)
+			if err == nil {
+				health.Export(signal, count(spooled), nil)
+				return nil
+			}
+			if !Retryable(err) {
+				// 不可重试的数据丢掉 避免阻塞队列
+				s.Drop()
+				return nil
+			}
+			return err
+		})
+		// 还有没重放完的数据时直接落盘 保证顺序
+		if err == nil {
+			err = invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil {
+				health.Export(signal, count(msg), nil)
+				return nil
+			}
+			if !Retryable(err) {
+				return err
+			}
+		}
+		data, mErr := proto.Marshal(msg)
+		if mErr != nil {
+			return err
+		}
+		if pErr := s.Push(data); pErr != nil {
+			return err
+		}
+		health.Spool(signal, count(msg))
+		return nil
+	}
+}
+
+// Retryable 收集器不可用时的grpc错误
+func Retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted,
+		codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
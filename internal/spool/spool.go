@@ -0,0 +1,169 @@
+// Package spool 导出失败时把数据落盘 恢复后按顺序重放
+package spool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const suffix = ".spool"
+
+// Spool 有大小上限的磁盘队列 超过上限时丢弃最旧的数据
+type Spool struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries []entry // 按写入顺序
+	size    int64
+	seq     uint64
+
+	drainMu sync.Mutex
+	dropped atomic.Int64
+}
+
+type entry struct {
+	name string
+	size int64
+}
+
+// New 打开目录 目录中已有的数据会在下次Drain时重放
+func New(dir string, maxBytes int64) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &Spool{dir: dir, maxBytes: maxBytes}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		// 上次退出时没写完的数据
+		if strings.HasSuffix(f.Name(), suffix+".tmp") {
+			_ = os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+		if !strings.HasSuffix(f.Name(), suffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), suffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		s.entries = append(s.entries, entry{name: f.Name(), size: info.Size()})
+		s.size += info.Size()
+		if seq > s.seq {
+			s.seq = seq
+		}
+	}
+	sort.Slice(s.entries, func(i, j int) bool {
+		return s.entries[i].name < s.entries[j].name
+	})
+	return s, nil
+}
+
+// Push 写入一条数据 超过上限时先丢弃最旧的数据
+func (s *Spool) Push(data []byte) error {
+	size := int64(len(data))
+	if size > s.maxBytes {
+		s.dropped.Add(1)
+		return fmt.Errorf("spool: payload of %v bytes exceeds limit %v", size, s.maxBytes)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.size+size > s.maxBytes && len(s.entries) > 0 {
+		s.removeLocked(s.entries[0].name)
+		s.dropped.Add(1)
+	}
+	s.seq++
+	name := fmt.Sprintf("%020d%v", s.seq, suffix)
+	// 先写临时文件再rename 避免进程退出时留下不完整的数据
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		s.dropped.Add(1)
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		_ = os.Remove(tmp)
+		s.dropped.Add(1)
+		return err
+	}
+	s.entries = append(s.entries, entry{name: name, size: size})
+	s.size += size
+	return nil
+}
+
+// Drain 按写入顺序重放 fn返回nil时删除该条数据 返回错误时停止
+func (s *Spool) Drain(fn func(data []byte) error) error {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	for {
+		s.mu.Lock()
+		if len(s.entries) == 0 {
+			s.mu.Unlock()
+			return nil
+		}
+		name := s.entries[0].name
+		s.mu.Unlock()
+
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err == nil {
+			err = fn(data)
+			if err != nil {
+				return err
+			}
+		}
+		// 读取失败的数据也直接删掉 避免阻塞队列
+		s.mu.Lock()
+		s.removeLocked(name)
+		s.mu.Unlock()
+	}
+}
+
+// Drop 记录一条被丢弃的数据 如重放时遇到不可重试的错误
+func (s *Spool) Drop() {
+	s.dropped.Add(1)
+}
+
+// Len 待重放的条数
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Size 待重放的字节数
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Dropped 累计丢弃的条数
+func (s *Spool) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+func (s *Spool) removeLocked(name string) {
+	for i, e := range s.entries {
+		if e.name == name {
+			_ = os.Remove(filepath.Join(s.dir, name))
+			s.size -= e.size
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return
+		}
+	}
+}
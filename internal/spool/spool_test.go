@@ -0,0 +1,125 @@
+package spool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// 按顺序取出所有数据
+func drainAll(t *testing.T, s *Spool) []string {
+	t.Helper()
+	var got []string
+	if err := s.Drain(func(data []byte) error {
+		got = append(got, string(data))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func push(t *testing.T, s *Spool, items ...string) {
+	t.Helper()
+	for _, item := range items {
+		if err := s.Push([]byte(item)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSpoolFIFO(t *testing.T) {
+	s, err := New(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push(t, s, "a", "b", "c")
+	if s.Len() != 3 || s.Size() != 3 {
+		t.Errorf("len = %v size = %v, want 3 3", s.Len(), s.Size())
+	}
+	if got := drainAll(t, s); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("drained %v", got)
+	}
+	if s.Len() != 0 || s.Size() != 0 {
+		t.Errorf("len = %v size = %v after drain", s.Len(), s.Size())
+	}
+}
+
+func TestSpoolDropOldest(t *testing.T) {
+	s, err := New(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push(t, s, "1111", "2222", "3333")
+	if s.Dropped() != 1 {
+		t.Errorf("dropped = %v, want 1", s.Dropped())
+	}
+	// 超过上限的单条数据直接丢弃 不影响队列
+	if err := s.Push([]byte("12345678901")); err == nil {
+		t.Error("oversize payload accepted")
+	}
+	if s.Dropped() != 2 {
+		t.Errorf("dropped = %v, want 2", s.Dropped())
+	}
+	if got := drainAll(t, s); !reflect.DeepEqual(got, []string{"2222", "3333"}) {
+		t.Errorf("drained %v", got)
+	}
+}
+
+func TestSpoolReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push(t, s, "a", "b")
+	// 上次退出时没写完的数据
+	tmp := filepath.Join(dir, "00000000000000000003"+suffix+".tmp")
+	if err := os.WriteFile(tmp, []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = New(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("tmp file not removed: %v", err)
+	}
+	if s.Len() != 2 || s.Size() != 2 {
+		t.Errorf("len = %v size = %v, want 2 2", s.Len(), s.Size())
+	}
+	// 新数据排在重启前的数据后面
+	push(t, s, "c")
+	if got := drainAll(t, s); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("drained %v", got)
+	}
+}
+
+func TestSpoolDrainStopsOnError(t *testing.T) {
+	s, err := New(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push(t, s, "a", "b", "c")
+	down := errors.New("down")
+	var got []string
+	err = s.Drain(func(data []byte) error {
+		if string(data) == "b" {
+			return down
+		}
+		got = append(got, string(data))
+		return nil
+	})
+	if err != down {
+		t.Errorf("err = %v, want %v", err, down)
+	}
+	if !reflect.DeepEqual(got, []string{"a"}) || s.Len() != 2 {
+		t.Errorf("drained %v len = %v", got, s.Len())
+	}
+	if got := drainAll(t, s); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("drained %v", got)
+	}
+}
@@ -0,0 +1,31 @@
+package log
+
+import (
+	"context"
+	"github.com/watora/telemetry/config"
+	"go.opentelemetry.io/otel/baggage"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+)
+
+// 把ctx中baggage的指定key复制到日志属性上 需要注册在导出的processor之前
+type baggageProcessor struct {
+}
+
+func (p *baggageProcessor) OnEmit(ctx context.Context, record *log.Record) error {
+	bag := baggage.FromContext(ctx)
+	for _, key := range config.Global.BaggageKeys {
+		if member := bag.Member(key); member.Key() != "" {
+			record.AddAttributes(otellog.String(key, member.Value()))
+		}
+	}
+	return nil
+}
+
+func (p *baggageProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (p *baggageProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
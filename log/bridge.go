@@ -15,10 +15,12 @@ func LogxBridge() {
 		return
 	}
 	logProvider := global.GetLoggerProvider()
-	logx.AddWriter(&LogxWriter{
+	writer := &LogxWriter{
 		logger:    logProvider.Logger("telemetry_logx"),
 		callDepth: 6,
-	})
+	}
+	writer.sampler = newSampler(config.Global.LogSampling, writer.emitSamplingSummary)
+	logx.AddWriter(writer)
 }
 
 // ZapBridge 使zap导出otel日志
@@ -0,0 +1,105 @@
+package log
+
+import (
+	"context"
+	"github.com/watora/telemetry/config"
+	"github.com/zeromicro/go-zero/core/logx"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"strconv"
+)
+
+const (
+	traceIDKey    = "trace_id"
+	spanIDKey     = "span_id"
+	traceFlagsKey = "trace_flags"
+	baggageKey    = "baggage"
+	// logx.WithContext写入的trace字段
+	logxTraceKey = "trace"
+	logxSpanKey  = "span"
+)
+
+// 从ctx的span中取出trace_id span_id trace_flags作为日志字段
+// ctx本身以SkipType字段传入 stderr不输出 otelzap用它关联trace和baggage
+func ctxFields(ctx context.Context) []zap.Field {
+	fields := []zap.Field{{Key: "context", Type: zapcore.SkipType, Interface: ctx}}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return fields
+	}
+	return append(fields,
+		zap.String(traceIDKey, sc.TraceID().String()),
+		zap.String(spanIDKey, sc.SpanID().String()),
+		zap.String(traceFlagsKey, sc.TraceFlags().String()),
+	)
+}
+
+// logx的字段会交给所有writer输出 所以不能带ctx 只带trace_flags和BaggageKeys中的baggage
+// trace_id span_id由logx.WithContext以trace span字段写入 不再重复 LogxWriter再从字段还原span和baggage
+func logxCtxFields(ctx context.Context) []logx.LogField {
+	var fields []logx.LogField
+	sc := trace.SpanContextFromContext(ctx)
+	if sc.IsValid() {
+		fields = append(fields, logx.Field(traceFlagsKey, sc.TraceFlags().String()))
+	}
+	bag := baggage.FromContext(ctx)
+	var members []baggage.Member
+	for _, key := range config.Global.BaggageKeys {
+		if member := bag.Member(key); member.Key() != "" {
+			members = append(members, member)
+		}
+	}
+	if len(members) > 0 {
+		if b, err := baggage.New(members...); err == nil {
+			fields = append(fields, logx.Field(baggageKey, b.String()))
+		}
+	}
+	return fields
+}
+
+// 用logx字段中的baggage还原ctx的baggage 交给baggageProcessor复制到日志属性上
+func baggageFromFields(ctx context.Context, fields []logx.LogField) context.Context {
+	if baggage.FromContext(ctx).Len() > 0 {
+		return ctx
+	}
+	for _, field := range fields {
+		v, ok := field.Value.(string)
+		if !ok || field.Key != baggageKey {
+			continue
+		}
+		if b, err := baggage.Parse(v); err == nil {
+			return baggage.ContextWithBaggage(ctx, b)
+		}
+	}
+	return ctx
+}
+
+// 用logx字段中的trace(trace_id) span(span_id) trace_flags还原span context
+func spanContextFromFields(ctx context.Context, fields []logx.LogField) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	var cfg trace.SpanContextConfig
+	for _, field := range fields {
+		v, ok := field.Value.(string)
+		if !ok {
+			continue
+		}
+		switch field.Key {
+		case traceIDKey, logxTraceKey:
+			cfg.TraceID, _ = trace.TraceIDFromHex(v)
+		case spanIDKey, logxSpanKey:
+			cfg.SpanID, _ = trace.SpanIDFromHex(v)
+		case traceFlagsKey:
+			flags, _ := strconv.ParseUint(v, 16, 8)
+			cfg.TraceFlags = trace.TraceFlags(flags)
+		}
+	}
+	sc := trace.NewSpanContext(cfg)
+	if !sc.IsValid() {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
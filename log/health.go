@@ -0,0 +1,22 @@
+package log
+
+import (
+	"context"
+	"github.com/watora/telemetry/internal/health"
+	"go.opentelemetry.io/otel/sdk/log"
+)
+
+// 记录导出结果
+// 启用落盘时失败的数据会落盘并返回成功 由spoolProxy按收集器实际的结果记录成功
+type healthExporter struct {
+	log.Exporter
+	spooled bool
+}
+
+func (e *healthExporter) Export(ctx context.Context, records []log.Record) error {
+	err := e.Exporter.Export(ctx, records)
+	if err != nil || !e.spooled {
+		health.Export(health.Logs, len(records), err)
+	}
+	return err
+}
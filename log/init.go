@@ -4,23 +4,28 @@ import (
 	"context"
 	"fmt"
 	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/internal/identity"
 	"go.opentelemetry.io/contrib/bridges/otelzap"
-	"go.opentelemetry.io/contrib/processors/minsev"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	"go.opentelemetry.io/otel/log/global"
 	"go.opentelemetry.io/otel/sdk/log"
 	"go.opentelemetry.io/otel/sdk/resource"
-	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"net/http"
+	"net/url"
 	"os"
+	"time"
 )
 
+// 日志导出的超时 和otlploghttp的默认值一致 落盘代理要在这之前处理完请求
+const logExportTimeout = 10 * time.Second
+
 var defaultLogger *zap.Logger
 
 // Init 直接导出otel日志到collector
 func Init() {
-	res, err := buildResource(config.Global.AppName, config.Global.Version)
+	res, err := identity.Resource(config.Global.AppName, config.Global.Version)
 	if err != nil {
 		panic(fmt.Sprintf("build resource error: %v", err))
 	}
@@ -29,69 +34,98 @@ func Init() {
 	if err != nil {
 		panic(fmt.Sprintf("init provider: %v", err))
 	}
+	setup(loggerProvider)
+}
+
+// InitWithProcessor 使用指定的processor导出 如测试中记录到内存
+func InitWithProcessor(processor log.Processor) {
+	res, err := identity.Resource(config.Global.AppName, config.Global.Version)
+	if err != nil {
+		panic(fmt.Sprintf("build resource error: %v", err))
+	}
+	setup(newProviderWithProcessor(res, processor))
+}
+
+func setup(loggerProvider *log.LoggerProvider) {
+	initLevel()
 	// provider注册到全局
 	global.SetLoggerProvider(loggerProvider)
 	// init default logger
-	defaultLogger = initLogger(loggerProvider)
-}
-
-// 新建resource
-func buildResource(appName string, version string) (*resource.Resource, error) {
-	hostName, _ := os.Hostname()
-	// 新建resource
-	res, err := resource.Merge(resource.Default(),
-		resource.NewWithAttributes(semconv.SchemaURL,
-			semconv.ServiceName(appName),
-			semconv.ServiceVersion(version),
-			semconv.ServiceInstanceID(hostName),
-		))
+	logger, err := initLogger(loggerProvider)
 	if err != nil {
-		return nil, err
+		panic(fmt.Sprintf("init logger: %v", err))
 	}
-	return res, nil
+	defaultLogger = logger
 }
 
 func newLoggerProvider(res *resource.Resource, endPoint string) (*log.LoggerProvider, error) {
-	exporter, err := otlploghttp.New(context.Background(),
+	// 开发模式只输出到终端
+	if config.Global.DevMode {
+		return log.NewLoggerProvider(log.WithResource(res)), nil
+	}
+	opts := []otlploghttp.Option{
 		otlploghttp.WithInsecure(),
-		otlploghttp.WithEndpoint(endPoint))
+		otlploghttp.WithEndpoint(endPoint),
+		otlploghttp.WithTimeout(logExportTimeout),
+	}
+	if config.Global.SpoolDir != "" {
+		proxy, err := startSpoolProxy()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlploghttp.WithProxy(func(*http.Request) (*url.URL, error) {
+			return proxy, nil
+		}))
+	}
+	exporter, err := otlploghttp.New(context.Background(), opts...)
 	if err != nil {
 		return nil, err
 	}
-	level := minsev.SeverityInfo
-	if config.Global.Env == "local" {
-		level = minsev.SeverityDebug
-	}
-	processor := minsev.NewLogProcessor(log.NewBatchProcessor(exporter), level)
-	provider := log.NewLoggerProvider(
+	return newProviderWithProcessor(res, log.NewBatchProcessor(&healthExporter{Exporter: exporter, spooled: config.Global.SpoolDir != ""})), nil
+}
+
+func newProviderWithProcessor(res *resource.Resource, processor log.Processor) *log.LoggerProvider {
+	return log.NewLoggerProvider(
 		log.WithResource(res),
-		log.WithProcessor(processor),
+		log.WithProcessor(&baggageProcessor{}),
+		log.WithProcessor(&redactProcessor{}),
+		log.WithProcessor(&levelProcessor{processor}),
 	)
-	return provider, nil
 }
 
-// 初始化默认logger 输出到collector和stderr
-func initLogger(loggerProvider *log.LoggerProvider) *zap.Logger {
-	level := zapcore.InfoLevel
-	if config.Global.Env == "local" {
-		level = zapcore.DebugLevel
+// 初始化默认logger 输出到collector和stderr 配置了LogFile时同时写文件 级别可通过SetLevel在运行时修改
+func initLogger(loggerProvider *log.LoggerProvider) (*zap.Logger, error) {
+	cores := []zapcore.Core{
+		otelzap.NewCore("telemetry_zap", otelzap.WithLoggerProvider(loggerProvider)),
+		newStdCore(zapcore.DebugLevel),
 	}
-	otelCore := otelzap.NewCore("telemetry_zap", otelzap.WithLoggerProvider(loggerProvider))
-	stdCore := zapcore.NewCore(
-		zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
-		zapcore.Lock(os.Stderr),
-		level,
-	)
-	return zap.New(zapcore.NewTee(
-		otelCore,
-		stdCore,
-	), zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)).
-		With(zap.String("env", config.Global.Env))
+	fileCore, err := newFileCore(zapcore.DebugLevel)
+	if err != nil {
+		return nil, err
+	}
+	if fileCore != nil {
+		cores = append(cores, fileCore)
+	}
+	return zap.New(&levelCore{newSamplingCore(zapcore.NewTee(cores...))},
+		zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)).
+		With(zap.String("env", config.Global.Env)), nil
+}
+
+// 输出到stderr的core 开发模式下使用带颜色的文本格式
+func newStdCore(level zapcore.LevelEnabler) zapcore.Core {
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	if config.Global.DevMode {
+		encoderConfig := zap.NewDevelopmentEncoderConfig()
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		encoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout("15:04:05.000")
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	}
+	return &redactCore{zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), level)}
 }
 
 // GetLogger 生成指定服务的logger
 func GetLogger(appName string, version string) (*zap.Logger, error) {
-	res, err := buildResource(appName, version)
+	res, err := identity.Resource(appName, version)
 	if err != nil {
 		return nil, err
 	}
@@ -99,5 +133,5 @@ func GetLogger(appName string, version string) (*zap.Logger, error) {
 	if err != nil {
 		return nil, err
 	}
-	return initLogger(provider), nil
+	return initLogger(provider)
 }
@@ -0,0 +1,196 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/watora/telemetry/config"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// 全局日志级别 zap core和levelProcessor共用
+var (
+	level     = zap.NewAtomicLevel()
+	overrides sync.Map // logger name -> zapcore.Level
+	levelLock sync.Mutex
+)
+
+// 启动时根据env设置默认级别
+func initLevel() {
+	if config.Global.Env == "local" {
+		SetLevel(zapcore.DebugLevel)
+	} else {
+		SetLevel(zapcore.InfoLevel)
+	}
+}
+
+// SetLevel 运行时修改全局日志级别
+func SetLevel(l zapcore.Level) {
+	levelLock.Lock()
+	defer levelLock.Unlock()
+	level.SetLevel(l)
+}
+
+// GetLevel 当前全局日志级别
+func GetLevel() zapcore.Level {
+	return level.Level()
+}
+
+// SetLoggerLevel 修改指定名称logger(zap.Logger.Named)的级别 子logger(name.xxx)同样生效
+func SetLoggerLevel(name string, l zapcore.Level) {
+	levelLock.Lock()
+	defer levelLock.Unlock()
+	overrides.Store(name, l)
+}
+
+// ResetLoggerLevel 删除指定logger的级别 恢复使用全局级别
+func ResetLoggerLevel(name string) {
+	levelLock.Lock()
+	defer levelLock.Unlock()
+	overrides.Delete(name)
+}
+
+// LoggerLevels 所有单独设置过级别的logger
+func LoggerLevels() map[string]zapcore.Level {
+	levels := map[string]zapcore.Level{}
+	overrides.Range(func(key, value any) bool {
+		levels[key.(string)] = value.(zapcore.Level)
+		return true
+	})
+	return levels
+}
+
+func toSeverity(l zapcore.Level) otellog.Severity {
+	switch {
+	case l <= zapcore.DebugLevel:
+		return otellog.SeverityDebug
+	case l == zapcore.InfoLevel:
+		return otellog.SeverityInfo
+	case l == zapcore.WarnLevel:
+		return otellog.SeverityWarn
+	case l == zapcore.ErrorLevel:
+		return otellog.SeverityError
+	default:
+		return otellog.SeverityFatal
+	}
+}
+
+// 按logger名称取生效的级别 先精确匹配 再逐级匹配父logger
+func levelFor(name string) zapcore.Level {
+	for name != "" {
+		if l, ok := overrides.Load(name); ok {
+			return l.(zapcore.Level)
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return level.Level()
+}
+
+// 可以开启的最低级别 用于Enabled的快速判断
+func minLevel() zapcore.Level {
+	min := level.Level()
+	overrides.Range(func(_, value any) bool {
+		if l := value.(zapcore.Level); l < min {
+			min = l
+		}
+		return true
+	})
+	return min
+}
+
+// levelCore 按全局级别和logger名称的覆盖过滤日志 内部的core不再判断级别
+type levelCore struct {
+	zapcore.Core
+}
+
+func (c *levelCore) Enabled(l zapcore.Level) bool {
+	return l >= minLevel()
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{c.Core.With(fields)}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < levelFor(ent.LoggerName) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// levelProcessor 按scope名称取级别过滤导出的日志 otelzap用logger名称作为scope
+// 单独设置的级别只对该logger生效 其他日志(logx slog等)仍使用全局级别
+type levelProcessor struct {
+	log.Processor
+}
+
+func (p *levelProcessor) OnEmit(ctx context.Context, record *log.Record) error {
+	if record.Severity() < toSeverity(levelFor(record.InstrumentationScope().Name)) {
+		return nil
+	}
+	return p.Processor.OnEmit(ctx, record)
+}
+
+func (p *levelProcessor) Enabled(ctx context.Context, param log.EnabledParameters) bool {
+	return param.Severity >= toSeverity(levelFor(param.InstrumentationScope.Name))
+}
+
+type levelPayload struct {
+	Logger string `json:"logger,omitempty"`
+	Level  string `json:"level"`
+}
+
+type levelState struct {
+	Level   string            `json:"level"`
+	Loggers map[string]string `json:"loggers"`
+}
+
+// LevelHandler 查看和修改日志级别
+// GET 返回当前级别 PUT/POST {"level":"debug"} 修改全局级别
+// {"logger":"order","level":"debug"} 修改指定logger的级别 level为空时删除
+// 如 http.Handle("/debug/log/level", log.LevelHandler())
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var payload levelPayload
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if payload.Logger != "" && payload.Level == "" {
+				ResetLoggerLevel(payload.Logger)
+				break
+			}
+			l, err := zapcore.ParseLevel(payload.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if payload.Logger != "" {
+				SetLoggerLevel(payload.Logger, l)
+			} else {
+				SetLevel(l)
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		state := levelState{Level: GetLevel().String(), Loggers: map[string]string{}}
+		for name, l := range LoggerLevels() {
+			state.Loggers[name] = l.String()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(state)
+	})
+}
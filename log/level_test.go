@@ -0,0 +1,40 @@
+package log_test
+
+import (
+	"context"
+	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/log"
+	"github.com/watora/telemetry/telemetrytest"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	"go.uber.org/zap/zapcore"
+	"testing"
+)
+
+// 单独设置的级别只对该logger生效 不降低其他日志的导出级别
+func TestLoggerLevelOverrideIsScoped(t *testing.T) {
+	telemetrytest.Init(func(cfg *config.Config) {
+		cfg.AppName = "level"
+	})
+	log.SetLoggerLevel("order", zapcore.DebugLevel)
+	ctx := context.Background()
+
+	log.WithCtxDefault(ctx).Named("order").Debug("order debug")
+	log.WithCtxDefault(ctx).Named("order").Named("db").Debug("order db debug")
+	log.WithCtxDefault(ctx).Named("user").Debug("user debug")
+	log.CtxDebug(ctx, "default debug")
+	// logx slog等直接写otel logger的日志
+	other := global.GetLoggerProvider().Logger("telemetry_logx")
+	r := otellog.Record{}
+	r.SetBody(otellog.StringValue("logx debug"))
+	r.SetSeverity(otellog.SeverityDebug)
+	other.Emit(ctx, r)
+
+	var got []string
+	for _, r := range telemetrytest.LogsWithLevel(otellog.SeverityDebug) {
+		got = append(got, r.Body().AsString())
+	}
+	if len(got) != 2 || got[0] != "order debug" || got[1] != "order db debug" {
+		t.Errorf("debug logs = %v, want order and order.db only", got)
+	}
+}
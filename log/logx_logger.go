@@ -5,6 +5,7 @@ import (
 	"github.com/zeromicro/go-zero/core/logx"
 )
 
+// LogxWithCtx 带上ctx中span的trace span(logx自带 即trace_id span_id) trace_flags 以及BaggageKeys中的baggage
 func LogxWithCtx(ctx context.Context) logx.Logger {
-	return logx.WithContext(ctx).WithFields(logx.LogField{Key: "context", Value: ctx})
+	return logx.WithContext(ctx).WithFields(logxCtxFields(ctx)...)
 }
@@ -0,0 +1,60 @@
+package log_test
+
+import (
+	"context"
+	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/log"
+	"github.com/watora/telemetry/telemetrytest"
+	"github.com/watora/telemetry/trace"
+	"go.opentelemetry.io/otel/baggage"
+	otellog "go.opentelemetry.io/otel/log"
+	"testing"
+)
+
+func TestLogxWithCtxKeepsBaggage(t *testing.T) {
+	telemetrytest.Init(func(cfg *config.Config) {
+		cfg.AppName = "logx"
+		cfg.BaggageKeys = []string{"tenant_id"}
+	})
+	log.LogxBridge()
+
+	tenant, _ := baggage.NewMember("tenant_id", "t1")
+	secret, _ := baggage.NewMember("session", "s1")
+	bag, _ := baggage.New(tenant, secret)
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+	ctx, span := trace.StartTrace(ctx, "op")
+	log.LogxWithCtx(ctx).Info("hello")
+	span.End()
+
+	var found bool
+	for _, r := range telemetrytest.Logs() {
+		if r.Body().AsString() != "hello" {
+			continue
+		}
+		found = true
+		if r.TraceID() != span.SpanContext().TraceID() {
+			t.Errorf("trace id = %v, want %v", r.TraceID(), span.SpanContext().TraceID())
+		}
+		attrs := map[string]string{}
+		r.WalkAttributes(func(kv otellog.KeyValue) bool {
+			attrs[kv.Key] = kv.Value.String()
+			return true
+		})
+		if attrs["tenant_id"] != "t1" {
+			t.Errorf("tenant_id = %q, want t1", attrs["tenant_id"])
+		}
+		// trace_id span_id沿用logx自带的trace span字段 不重复输出
+		if attrs["trace"] != span.SpanContext().TraceID().String() || attrs["span"] != span.SpanContext().SpanID().String() {
+			t.Errorf("trace = %q span = %q", attrs["trace"], attrs["span"])
+		}
+		// 只传递BaggageKeys中的key 传递用的字段不作为属性输出
+		for _, key := range []string{"session", "baggage", "trace_id", "span_id"} {
+			if v, ok := attrs[key]; ok {
+				t.Errorf("unexpected attribute %v=%v", key, v)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("logx record not found")
+	}
+}
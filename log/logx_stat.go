@@ -0,0 +1,106 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/metrics"
+	"github.com/zeromicro/go-zero/core/logx"
+	"go.opentelemetry.io/otel/attribute"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// go-zero stat日志的格式 见core/stat core/load core/stores/cache core/collection
+var (
+	statReportRe = regexp.MustCompile(`^\((.+)\) - qps: ([\d.]+)/s, drops: (\d+), avg time: ([\d.]+)ms, med: ([\d.]+)ms, 90th: ([\d.]+)ms, 99th: ([\d.]+)ms, 99\.9th: ([\d.]+)ms`)
+	sheddingRe   = regexp.MustCompile(`^\((.+)\) shedding_stat(?:_drop)? \[1m\], cpu: (\d+), total: \d+, pass: (\d+), drop: (\d+)`)
+	cpuUsageRe   = regexp.MustCompile(`^CPU: (\d+)m,`)
+	cacheStatRe  = regexp.MustCompile(`^(?:db)?cache\((.+)\) - qpm: \d+, hit_ratio: [\d.]+%, (?:elements: \d+, )?hit: (\d+), miss: (\d+)(?:, db_fails: (\d+))?`)
+	slowKindRe   = regexp.MustCompile(`^\[(\w+)\]`)
+)
+
+// 是否把stat和slow日志转成指标
+func logxMetricsEnabled() bool {
+	return config.Global.UseMetrics && config.Global.LogxMetrics != ""
+}
+
+// 只上报指标时不再输出日志
+func logxLogEnabled() bool {
+	return !config.Global.UseMetrics || config.Global.LogxMetrics != "metrics"
+}
+
+// 解析go-zero的stat日志 无法识别的格式忽略
+func emitStatMetrics(ctx context.Context, v any) {
+	line, ok := v.(string)
+	if !ok {
+		return
+	}
+	if m := statReportRe.FindStringSubmatch(line); m != nil {
+		name := attribute.String("name", m[1])
+		metrics.EmitGauge(ctx, "gozero_qps", roundFloat(m[2]), name)
+		metrics.EmitCount(ctx, "gozero_drops", parseInt(m[3]), name)
+		for i, quantile := range []string{"avg", "p50", "p90", "p99", "p999"} {
+			metrics.EmitGauge(ctx, "gozero_latency", roundFloat(m[4+i]), name, attribute.String("quantile", quantile))
+		}
+		return
+	}
+	if m := sheddingRe.FindStringSubmatch(line); m != nil {
+		name := attribute.String("name", m[1])
+		metrics.EmitGauge(ctx, "gozero_cpu_usage", parseInt(m[2]))
+		metrics.EmitCount(ctx, "gozero_shedding_count", parseInt(m[3]), name, attribute.String("result", "pass"))
+		metrics.EmitCount(ctx, "gozero_shedding_count", parseInt(m[4]), name, attribute.String("result", "drop"))
+		return
+	}
+	if m := cpuUsageRe.FindStringSubmatch(line); m != nil {
+		metrics.EmitGauge(ctx, "gozero_cpu_usage", parseInt(m[1]))
+		return
+	}
+	if m := cacheStatRe.FindStringSubmatch(line); m != nil {
+		cache := attribute.String("cache", m[1])
+		metrics.EmitCount(ctx, "gozero_cache_count", parseInt(m[2]), cache, attribute.String("result", "hit"))
+		metrics.EmitCount(ctx, "gozero_cache_count", parseInt(m[3]), cache, attribute.String("result", "miss"))
+		if m[4] != "" {
+			metrics.EmitCount(ctx, "gozero_cache_count", parseInt(m[4]), cache, attribute.String("result", "db_fail"))
+		}
+	}
+}
+
+// slow日志按类型([HTTP] [RPC] [SQL] [REDIS] [MONGO])和调用位置计数 带duration字段时记录耗时
+func emitSlowMetrics(ctx context.Context, v any, fields []logx.LogField) {
+	kind := "other"
+	if m := slowKindRe.FindStringSubmatch(fmt.Sprint(v)); m != nil {
+		kind = strings.ToLower(m[1])
+	}
+	var caller string
+	var duration time.Duration
+	for _, field := range fields {
+		s, ok := field.Value.(string)
+		if !ok {
+			continue
+		}
+		switch field.Key {
+		case "caller":
+			caller = s
+		case "duration":
+			duration, _ = time.ParseDuration(s)
+		}
+	}
+	metrics.EmitCount(ctx, "gozero_slow_call_count", 1, attribute.String("kind", kind), attribute.String("caller", caller))
+	if duration > 0 {
+		metrics.EmitTime(ctx, "gozero_slow_call_duration", duration.Milliseconds(), attribute.String("kind", kind))
+	}
+}
+
+func parseInt(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+func roundFloat(s string) int64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return int64(math.Round(f))
+}
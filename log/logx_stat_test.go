@@ -0,0 +1,154 @@
+package log_test
+
+import (
+	"fmt"
+	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/log"
+	"github.com/watora/telemetry/telemetrytest"
+	"github.com/zeromicro/go-zero/core/logx"
+	"go.opentelemetry.io/otel/attribute"
+	"strings"
+	"testing"
+)
+
+// 期望的指标值 gauge为true时断言gauge 否则断言counter
+type statMetric struct {
+	name  string
+	attrs []attribute.KeyValue
+	value int64
+	gauge bool
+}
+
+func newStatWriter(t *testing.T) *log.LogxWriter {
+	t.Helper()
+	telemetrytest.Init(func(cfg *config.Config) {
+		cfg.AppName = "stat"
+		cfg.LogxMetrics = "metrics"
+	})
+	return &log.LogxWriter{}
+}
+
+// 日志格式和go-zero v1.8.3中的Statf一致
+func TestLogxStatMetrics(t *testing.T) {
+	name := attribute.String("name", "api")
+	tests := []struct {
+		name string
+		line string
+		want []statMetric
+	}{
+		{
+			name: "stat report",
+			// core/stat/metrics.go
+			line: fmt.Sprintf("(%s) - qps: %.1f/s, drops: %d, avg time: %.1fms, med: %.1fms, "+
+				"90th: %.1fms, 99th: %.1fms, 99.9th: %.1fms",
+				"api", 12.6, 2, 3.4, 2.5, 8.0, 20.5, 40.1),
+			want: []statMetric{
+				{name: "gozero_qps", attrs: []attribute.KeyValue{name}, value: 13, gauge: true},
+				{name: "gozero_drops", attrs: []attribute.KeyValue{name}, value: 2},
+				{name: "gozero_latency", attrs: []attribute.KeyValue{name, attribute.String("quantile", "avg")}, value: 3, gauge: true},
+				{name: "gozero_latency", attrs: []attribute.KeyValue{name, attribute.String("quantile", "p50")}, value: 3, gauge: true},
+				{name: "gozero_latency", attrs: []attribute.KeyValue{name, attribute.String("quantile", "p90")}, value: 8, gauge: true},
+				{name: "gozero_latency", attrs: []attribute.KeyValue{name, attribute.String("quantile", "p99")}, value: 21, gauge: true},
+				{name: "gozero_latency", attrs: []attribute.KeyValue{name, attribute.String("quantile", "p999")}, value: 40, gauge: true},
+			},
+		},
+		{
+			name: "shedding stat",
+			// core/load/sheddingstat.go
+			line: fmt.Sprintf("(%s) shedding_stat [1m], cpu: %d, total: %d, pass: %d, drop: %d", "api", 350, 100, 100, 0),
+			want: []statMetric{
+				{name: "gozero_cpu_usage", value: 350, gauge: true},
+				{name: "gozero_shedding_count", attrs: []attribute.KeyValue{name, attribute.String("result", "pass")}, value: 100},
+				{name: "gozero_shedding_count", attrs: []attribute.KeyValue{name, attribute.String("result", "drop")}, value: 0},
+			},
+		},
+		{
+			name: "shedding stat drop",
+			line: fmt.Sprintf("(%s) shedding_stat_drop [1m], cpu: %d, total: %d, pass: %d, drop: %d", "api", 920, 100, 70, 30),
+			want: []statMetric{
+				{name: "gozero_cpu_usage", value: 920, gauge: true},
+				{name: "gozero_shedding_count", attrs: []attribute.KeyValue{name, attribute.String("result", "pass")}, value: 70},
+				{name: "gozero_shedding_count", attrs: []attribute.KeyValue{name, attribute.String("result", "drop")}, value: 30},
+			},
+		},
+		{
+			name: "cpu usage",
+			// core/stat/usage.go
+			line: fmt.Sprintf("CPU: %dm, MEMORY: Alloc=%.1fMi, TotalAlloc=%.1fMi, Sys=%.1fMi, NumGC=%d", 125, 10.5, 200.2, 30.0, 12),
+			want: []statMetric{
+				{name: "gozero_cpu_usage", value: 125, gauge: true},
+			},
+		},
+		{
+			name: "sql cache",
+			// core/stores/cache/cachestat.go
+			line: fmt.Sprintf("dbcache(%s) - qpm: %d, hit_ratio: %.1f%%, hit: %d, miss: %d, db_fails: %d", "user", 100, 90.0, 90, 10, 1),
+			want: []statMetric{
+				{name: "gozero_cache_count", attrs: []attribute.KeyValue{attribute.String("cache", "user"), attribute.String("result", "hit")}, value: 90},
+				{name: "gozero_cache_count", attrs: []attribute.KeyValue{attribute.String("cache", "user"), attribute.String("result", "miss")}, value: 10},
+				{name: "gozero_cache_count", attrs: []attribute.KeyValue{attribute.String("cache", "user"), attribute.String("result", "db_fail")}, value: 1},
+			},
+		},
+		{
+			name: "collection cache",
+			// core/collection/cache.go
+			line: fmt.Sprintf("cache(%s) - qpm: %d, hit_ratio: %.1f%%, elements: %d, hit: %d, miss: %d", "local", 50, 80.0, 7, 40, 10),
+			want: []statMetric{
+				{name: "gozero_cache_count", attrs: []attribute.KeyValue{attribute.String("cache", "local"), attribute.String("result", "hit")}, value: 40},
+				{name: "gozero_cache_count", attrs: []attribute.KeyValue{attribute.String("cache", "local"), attribute.String("result", "miss")}, value: 10},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newStatWriter(t).Stat(tt.line)
+			for _, m := range tt.want {
+				if m.gauge {
+					telemetrytest.AssertGauge(t, m.name, m.attrs, m.value)
+				} else {
+					telemetrytest.AssertCounter(t, m.name, m.attrs, m.value)
+				}
+			}
+		})
+	}
+}
+
+func TestLogxStatIgnoresUnknown(t *testing.T) {
+	newStatWriter(t).Stat("p2c - conn: 127.0.0.1:8080, load: 10, reqs: 5")
+	for _, sm := range telemetrytest.Collect(t).ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if strings.HasPrefix(m.Name, "stat_gozero_") {
+				t.Errorf("unexpected metric %v", m.Name)
+			}
+		}
+	}
+}
+
+// slow日志格式和go-zero v1.8.3中的Slowf一致
+func TestLogxSlowMetrics(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		kind string
+	}{
+		// rest/handler/loghandler.go
+		{name: "http", line: fmt.Sprintf("[HTTP] %s - %s %s - %s - %s - slowcall(%s)", "200", "GET", "/ping", "127.0.0.1", "curl", "1.5s"), kind: "http"},
+		// zrpc/internal/serverinterceptors/statinterceptor.go
+		{name: "rpc", line: fmt.Sprintf("[RPC] slowcall - %s - %s", "127.0.0.1", "/user.User/Get"), kind: "rpc"},
+		// core/stores/sqlx/stmt.go
+		{name: "sql", line: fmt.Sprintf("[SQL] %s: slowcall - %s", "query", "select 1"), kind: "sql"},
+		// core/stores/redis/durationhook.go
+		{name: "redis", line: fmt.Sprintf("[REDIS] slowcall on executing: %s", "get k"), kind: "redis"},
+		// core/stores/mon/util.go
+		{name: "mongo", line: fmt.Sprintf("[MONGO] mongo(%s) - slowcall - %s - ok", "users", "Find"), kind: "mongo"},
+		{name: "other", line: "slowcall", kind: "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newStatWriter(t).Slow(tt.line, logx.Field("caller", "user/logic.go:10"), logx.Field("duration", "1500.0ms"))
+			kind := attribute.String("kind", tt.kind)
+			telemetrytest.AssertCounter(t, "gozero_slow_call_count", []attribute.KeyValue{kind, attribute.String("caller", "user/logic.go:10")}, 1)
+			telemetrytest.AssertHistogramCount(t, "gozero_slow_call_duration", []attribute.KeyValue{kind}, 1)
+		})
+	}
+}
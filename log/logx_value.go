@@ -0,0 +1,120 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.opentelemetry.io/otel/log"
+	"math"
+	"reflect"
+	"time"
+)
+
+// 嵌套超过这个深度的值直接转成字符串 避免循环引用
+const maxValueDepth = 8
+
+// 把logx传入的任意值转成otel的日志值 时间和时长和otelzap一样使用纳秒
+func toLogValue(v any) log.Value {
+	return toLogValueDepth(v, 0)
+}
+
+func toLogValueDepth(v any, depth int) log.Value {
+	// 指针类型的nil error或Stringer 调用方法会panic
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return log.Value{}
+	}
+	switch val := v.(type) {
+	case nil:
+		return log.Value{}
+	case log.Value:
+		return val
+	case string:
+		return log.StringValue(val)
+	case []byte:
+		return log.BytesValue(val)
+	case bool:
+		return log.BoolValue(val)
+	case int:
+		return log.IntValue(val)
+	case int8:
+		return log.Int64Value(int64(val))
+	case int16:
+		return log.Int64Value(int64(val))
+	case int32:
+		return log.Int64Value(int64(val))
+	case int64:
+		return log.Int64Value(val)
+	case uint:
+		return uintValue(uint64(val))
+	case uint8:
+		return log.Int64Value(int64(val))
+	case uint16:
+		return log.Int64Value(int64(val))
+	case uint32:
+		return log.Int64Value(int64(val))
+	case uint64:
+		return uintValue(val)
+	case float32:
+		return log.Float64Value(float64(val))
+	case float64:
+		return log.Float64Value(val)
+	case time.Time:
+		return log.Int64Value(val.UnixNano())
+	case time.Duration:
+		return log.Int64Value(val.Nanoseconds())
+	case error:
+		return log.StringValue(val.Error())
+	case fmt.Stringer:
+		return log.StringValue(val.String())
+	}
+	if depth >= maxValueDepth {
+		return log.StringValue(fmt.Sprintf("%+v", v))
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		return toLogValueDepth(rv.Elem().Interface(), depth+1)
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return log.Value{}
+		}
+		values := make([]log.Value, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			values = append(values, toLogValueDepth(rv.Index(i).Interface(), depth+1))
+		}
+		return log.SliceValue(values...)
+	case reflect.Map:
+		if rv.IsNil() {
+			return log.Value{}
+		}
+		kvs := make([]log.KeyValue, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			kvs = append(kvs, log.KeyValue{
+				Key:   fmt.Sprint(iter.Key().Interface()),
+				Value: toLogValueDepth(iter.Value().Interface(), depth+1),
+			})
+		}
+		return log.MapValue(kvs...)
+	case reflect.Struct:
+		// 结构体按json的字段名和tag展开
+		data, err := json.Marshal(v)
+		if err != nil {
+			return log.StringValue(fmt.Sprintf("%+v", v))
+		}
+		var decoded any
+		if err = json.Unmarshal(data, &decoded); err != nil {
+			return log.StringValue(string(data))
+		}
+		return toLogValueDepth(decoded, depth+1)
+	default:
+		return log.StringValue(fmt.Sprintf("%+v", v))
+	}
+}
+
+// 超过int64范围的uint转成字符串
+func uintValue(v uint64) log.Value {
+	if v > math.MaxInt64 {
+		return log.StringValue(fmt.Sprint(v))
+	}
+	return log.Int64Value(int64(v))
+}
@@ -0,0 +1,111 @@
+package log
+
+import (
+	"errors"
+	"go.opentelemetry.io/otel/log"
+	"math"
+	"testing"
+	"time"
+)
+
+type valueError struct{ msg string }
+
+func (e *valueError) Error() string { return e.msg }
+
+type valueStringer struct{ name string }
+
+func (s *valueStringer) String() string { return s.name }
+
+type valueStruct struct {
+	Name    string `json:"name"`
+	Count   int    `json:"count"`
+	Ignored string `json:"-"`
+	Inner   *struct {
+		OK bool `json:"ok"`
+	} `json:"inner,omitempty"`
+}
+
+func TestToLogValue(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	var nilErr *valueError
+	var nilStringer *valueStringer
+	var nilMap map[string]int
+	var nilSlice []int
+	n := 5
+	deep := map[string]any{"leaf": 1}
+	for i := 0; i < 2*maxValueDepth; i++ {
+		deep = map[string]any{"next": deep}
+	}
+
+	tests := []struct {
+		name string
+		in   any
+		want log.Value
+	}{
+		{"nil", nil, log.Value{}},
+		{"value", log.StringValue("v"), log.StringValue("v")},
+		{"string", "s", log.StringValue("s")},
+		{"bytes", []byte("ab"), log.BytesValue([]byte("ab"))},
+		{"bool", true, log.BoolValue(true)},
+		{"int", -3, log.IntValue(-3)},
+		{"int8", int8(-8), log.Int64Value(-8)},
+		{"uint8", uint8(8), log.Int64Value(8)},
+		{"uint32", uint32(math.MaxUint32), log.Int64Value(math.MaxUint32)},
+		{"uint64", uint64(7), log.Int64Value(7)},
+		{"uint64 overflow", uint64(math.MaxUint64), log.StringValue("18446744073709551615")},
+		{"uint overflow", uint(math.MaxInt64) + 1, log.StringValue("9223372036854775808")},
+		{"float32", float32(1.5), log.Float64Value(1.5)},
+		{"float64", 2.25, log.Float64Value(2.25)},
+		{"time", ts, log.Int64Value(ts.UnixNano())},
+		{"duration", 2 * time.Second, log.Int64Value(int64(2 * time.Second))},
+		{"error", errors.New("boom"), log.StringValue("boom")},
+		{"pointer error", &valueError{"bad"}, log.StringValue("bad")},
+		{"stringer", &valueStringer{"name"}, log.StringValue("name")},
+		{"typed nil error", nilErr, log.Value{}},
+		{"typed nil error as error", error(nilErr), log.Value{}},
+		{"typed nil stringer", nilStringer, log.Value{}},
+		{"nil map", nilMap, log.Value{}},
+		{"nil slice", nilSlice, log.Value{}},
+		{"pointer", &n, log.IntValue(5)},
+		{"slice", []any{1, "a", nil}, log.SliceValue(log.IntValue(1), log.StringValue("a"), log.Value{})},
+		{"array", [2]bool{true, false}, log.SliceValue(log.BoolValue(true), log.BoolValue(false))},
+		{"map", map[int]string{1: "a", 2: "b"}, log.MapValue(log.String("1", "a"), log.String("2", "b"))},
+		{
+			"struct",
+			valueStruct{Name: "n", Count: 2, Ignored: "x"},
+			log.MapValue(log.String("name", "n"), log.Float64("count", 2)),
+		},
+		{
+			"nested struct pointer",
+			&valueStruct{Name: "n", Inner: &struct {
+				OK bool `json:"ok"`
+			}{true}},
+			log.MapValue(log.String("name", "n"), log.Float64("count", 0), log.Map("inner", log.Bool("ok", true))),
+		},
+		{"unsupported", make(chan int), log.StringValue("")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toLogValue(tt.in)
+			if tt.name == "unsupported" {
+				// chan的地址每次不同 只检查类型
+				if got.Kind() != log.KindString {
+					t.Errorf("kind = %v, want string", got.Kind())
+				}
+				return
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("toLogValue(%v) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	// 超过深度后转成字符串
+	got := toLogValue(deep)
+	for i := 0; i < maxValueDepth && got.Kind() == log.KindMap; i++ {
+		got = got.AsMap()[0].Value
+	}
+	if got.Kind() != log.KindString {
+		t.Errorf("deep value kind = %v, want string", got.Kind())
+	}
+}
@@ -2,13 +2,11 @@ package log
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/watora/telemetry/config"
 	"github.com/zeromicro/go-zero/core/logx"
 	"go.opentelemetry.io/otel/log"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
-	"reflect"
 	"runtime"
 	"strings"
 	"time"
@@ -17,6 +15,7 @@ import (
 type LogxWriter struct {
 	logger    log.Logger
 	callDepth int
+	sampler   *sampler
 }
 
 func (w *LogxWriter) Alert(v any) {
@@ -44,20 +43,37 @@ func (w *LogxWriter) Severe(v any) {
 }
 
 func (w *LogxWriter) Slow(v any, fields ...logx.LogField) {
-	w.Emit(v, log.SeverityWarn, fields...)
+	if logxMetricsEnabled() {
+		emitSlowMetrics(logxContext(context.Background(), fields), v, fields)
+	}
+	if logxLogEnabled() {
+		w.Emit(v, log.SeverityWarn, fields...)
+	}
 }
 
 func (w *LogxWriter) Stack(v any) {
 	w.Emit(v, log.SeverityError)
 }
 
+// Stat 默认丢弃 开启LogxMetrics后转成指标 both时同时输出info日志
 func (w *LogxWriter) Stat(v any, fields ...logx.LogField) {
+	if !logxMetricsEnabled() {
+		return
+	}
+	emitStatMetrics(context.Background(), v)
+	if config.Global.LogxMetrics == "both" {
+		w.Emit(v, log.SeverityInfo, fields...)
+	}
 }
 
 func (w *LogxWriter) Emit(v any, level log.Severity, fields ...logx.LogField) {
+	if w.sampler != nil && !w.sampler.allow(strings.ToLower(level.String()), fmt.Sprint(v)) {
+		return
+	}
 	r := log.Record{}
 	r.SetTimestamp(time.Now())
-	r.SetBody(log.StringValue(v.(string)))
+	// Infov等传入结构体或map时body是map
+	r.SetBody(toLogValue(v))
 	r.SetSeverity(level)
 	r.SetSeverityText(strings.ToLower(level.String()))
 
@@ -88,32 +104,37 @@ func (w *LogxWriter) Emit(v any, level log.Severity, fields ...logx.LogField) {
 			ctx = c
 			continue
 		}
-		rv := reflect.ValueOf(field.Value)
-		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		// 还原到ctx中 由baggageProcessor按BaggageKeys添加属性
+		if field.Key == baggageKey {
 			continue
 		}
-		if rv.Kind() == reflect.Ptr {
-			rv = rv.Elem()
-		}
-		actualValue := rv.Interface()
-		switch actualValue.(type) {
-		case string:
-			r.AddAttributes(log.String(field.Key, field.Value.(string)))
-		case int:
-			r.AddAttributes(log.Int(field.Key, field.Value.(int)))
-		case int64:
-			r.AddAttributes(log.Int64(field.Key, field.Value.(int64)))
-		case int32:
-			r.AddAttributes(log.Int(field.Key, int(field.Value.(int32))))
-		case float64:
-			r.AddAttributes(log.Float64(field.Key, field.Value.(float64)))
-		case float32:
-			r.AddAttributes(log.Float64(field.Key, float64(field.Value.(float32))))
-		default:
-			d, _ := json.Marshal(field.Value)
-			r.AddAttributes(log.String(field.Key, string(d)))
+		value := toLogValue(field.Value)
+		if value.Empty() {
+			continue
 		}
+		r.AddAttributes(log.KeyValue{Key: field.Key, Value: value})
 	}
 	r.AddAttributes(log.String("env", config.Global.Env))
-	w.logger.Emit(ctx, r)
+	w.logger.Emit(logxContext(ctx, fields), r)
+}
+
+// 从logx字段还原span和baggage
+func logxContext(ctx context.Context, fields []logx.LogField) context.Context {
+	return baggageFromFields(spanContextFromFields(ctx, fields), fields)
+}
+
+// 采样丢弃的汇总记录
+func (w *LogxWriter) emitSamplingSummary(level string, message string, dropped int64) {
+	r := log.Record{}
+	r.SetTimestamp(time.Now())
+	r.SetBody(log.StringValue(samplingSummaryMessage))
+	r.SetSeverity(log.SeverityWarn)
+	r.SetSeverityText(strings.ToLower(log.SeverityWarn.String()))
+	r.AddAttributes(
+		log.String("sampled_level", level),
+		log.String("sampled_message", message),
+		log.Int64("dropped", dropped),
+		log.String("env", config.Global.Env),
+	)
+	w.logger.Emit(context.Background(), r)
 }
@@ -0,0 +1,110 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/watora/telemetry/internal/redact"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 对otel日志的消息和属性脱敏 需要注册在baggageProcessor之后 导出的processor之前
+type redactProcessor struct {
+}
+
+func (p *redactProcessor) OnEmit(ctx context.Context, record *log.Record) error {
+	r := redact.Current()
+	if r == nil {
+		return nil
+	}
+	body := record.Body()
+	if body.Kind() == otellog.KindString {
+		record.SetBody(otellog.StringValue(r.Message(body.AsString())))
+	} else if v, ok := r.LogValue("", body); ok {
+		record.SetBody(v)
+	}
+	attrs := make([]otellog.KeyValue, 0, record.AttributesLen())
+	record.WalkAttributes(func(kv otellog.KeyValue) bool {
+		if v, ok := r.LogValue(kv.Key, kv.Value); ok {
+			attrs = append(attrs, otellog.KeyValue{Key: kv.Key, Value: v})
+		}
+		return true
+	})
+	record.SetAttributes(attrs...)
+	return nil
+}
+
+func (p *redactProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (p *redactProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// redactCore 输出到stderr前脱敏 otel日志由redactProcessor处理
+type redactCore struct {
+	zapcore.Core
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{c.Core.With(redactFields(redact.Current(), fields))}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	r := redact.Current()
+	ent.Message = r.Message(ent.Message)
+	return c.Core.Write(ent, redactFields(r, fields))
+}
+
+// 字段按字符串形式匹配 命中后替换为字符串字段
+func redactFields(r *redact.Redactor, fields []zapcore.Field) []zapcore.Field {
+	if r == nil {
+		return fields
+	}
+	result := make([]zapcore.Field, 0, len(fields))
+	for _, field := range fields {
+		if field.Type == zapcore.SkipType || field.Type == zapcore.NamespaceType {
+			result = append(result, field)
+			continue
+		}
+		value := fieldString(field)
+		s, ok := r.String(field.Key, value)
+		if !ok {
+			continue
+		}
+		if s != value {
+			field = zap.String(field.Key, s)
+		}
+		result = append(result, field)
+	}
+	return result
+}
+
+func fieldString(field zapcore.Field) string {
+	if field.Type == zapcore.StringType {
+		return field.String
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+	switch v := enc.Fields[field.Key].(type) {
+	case string:
+		return v
+	case map[string]any, []any:
+		data, err := json.Marshal(v)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(enc.Fields[field.Key])
+}
@@ -0,0 +1,228 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/watora/telemetry/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"io"
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000"
+
+// 相同路径的logger共用一个writer
+var (
+	fileWriters     = map[string]*rotateWriter{}
+	fileWritersLock sync.Mutex
+)
+
+// 写到本地文件的core 没有配置Path时返回nil
+func newFileCore(level zapcore.LevelEnabler) (zapcore.Core, error) {
+	cfg := config.Global.LogFile
+	if cfg.Path == "" {
+		return nil, nil
+	}
+	fileWritersLock.Lock()
+	defer fileWritersLock.Unlock()
+	writer, ok := fileWriters[cfg.Path]
+	if !ok {
+		var err error
+		writer, err = newRotateWriter(cfg)
+		if err != nil {
+			return nil, err
+		}
+		fileWriters[cfg.Path] = writer
+	}
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	return &redactCore{zapcore.NewCore(encoder, writer, level)}, nil
+}
+
+// rotateWriter 同步写文件 超过大小或时间间隔后轮转 旧文件的压缩和清理在后台进行
+type rotateWriter struct {
+	cfg      config.LogFile
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	cleanMu  sync.Mutex
+}
+
+func newRotateWriter(cfg config.LogFile) (*rotateWriter, error) {
+	w := &rotateWriter{cfg: cfg}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := w.open(true); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// resume为true时沿用已有文件的开始时间 重启不会打断按时间轮转的周期
+func (w *rotateWriter) open(resume bool) error {
+	file, err := os.OpenFile(w.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	w.openedAt = time.Now()
+	if resume && w.size > 0 {
+		w.openedAt = w.startedAt(info)
+	}
+	return nil
+}
+
+// 当前文件从上次轮转开始写入 取最新的旧文件名中的时间 没有旧文件时取修改时间
+func (w *rotateWriter) startedAt(info os.FileInfo) time.Time {
+	prefix := filepath.Base(w.backupPrefix()) + "-"
+	for _, backup := range w.backups() {
+		name := strings.TrimPrefix(filepath.Base(backup), prefix)
+		if len(name) < len(backupTimeFormat) {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, name[:len(backupTimeFormat)], time.Local)
+		if err == nil && t.Before(info.ModTime()) {
+			return t
+		}
+		break
+	}
+	return info.ModTime()
+}
+
+func (w *rotateWriter) backupPrefix() string {
+	return strings.TrimSuffix(w.cfg.Path, filepath.Ext(w.cfg.Path))
+}
+
+// 所有旧文件 包括压缩后的 最新的在前
+func (w *rotateWriter) backups() []string {
+	backups, _ := filepath.Glob(w.backupPrefix() + "-[0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9]T*" + filepath.Ext(w.cfg.Path) + "*")
+	// 文件名中的时间可以直接按字符串排序 同一毫秒的序号_1 _2排在不带序号的之后
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups
+}
+
+// 旧文件名带上毫秒时间 同一毫秒内多次轮转时加上序号 避免覆盖还没压缩的文件
+func (w *rotateWriter) backupName(now time.Time) string {
+	ext := filepath.Ext(w.cfg.Path)
+	base := fmt.Sprintf("%s-%s", w.backupPrefix(), now.Format(backupTimeFormat))
+	name := base + ext
+	for seq := 1; exists(name) || exists(name+".gz"); seq++ {
+		name = fmt.Sprintf("%s_%d%s", base, seq, ext)
+	}
+	return name
+}
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			// 轮转失败继续写当前文件
+			stdlog.Printf("rotate log file %v: %v", w.cfg.Path, err)
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotateWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Sync()
+}
+
+func (w *rotateWriter) shouldRotate(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.cfg.MaxSize > 0 && w.size+n > int64(w.cfg.MaxSize)<<20 {
+		return true
+	}
+	return w.cfg.RotateInterval > 0 && time.Since(w.openedAt) >= w.cfg.RotateInterval
+}
+
+func (w *rotateWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	backup := w.backupName(time.Now())
+	renameErr := os.Rename(w.cfg.Path, backup)
+	// 无论改名是否成功都要重新打开文件
+	if err := w.open(false); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	go w.clean(backup)
+	return nil
+}
+
+// 压缩刚轮转的文件 删除超出数量和时长的旧文件
+func (w *rotateWriter) clean(backup string) {
+	w.cleanMu.Lock()
+	defer w.cleanMu.Unlock()
+	if w.cfg.Compress {
+		if err := gzipFile(backup); err != nil {
+			stdlog.Printf("compress log file %v: %v", backup, err)
+		}
+	}
+	for i, file := range w.backups() {
+		remove := w.cfg.MaxBackups > 0 && i >= w.cfg.MaxBackups
+		if !remove && w.cfg.MaxAge > 0 {
+			if info, err := os.Stat(file); err == nil && time.Since(info.ModTime()) > w.cfg.MaxAge {
+				remove = true
+			}
+		}
+		if remove {
+			_ = os.Remove(file)
+		}
+	}
+}
+
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err != nil {
+		_ = zw.Close()
+		_ = dst.Close()
+		_ = os.Remove(name + ".gz")
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
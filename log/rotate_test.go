@@ -0,0 +1,123 @@
+package log
+
+import (
+	"github.com/watora/telemetry/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name string, data string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupNameSequence(t *testing.T) {
+	dir := t.TempDir()
+	w := &rotateWriter{cfg: config.LogFile{Path: filepath.Join(dir, "app.log")}}
+	now := time.Date(2025, 1, 2, 3, 4, 5, 6e6, time.Local)
+	base := filepath.Join(dir, "app-20250102T030405.006")
+
+	want := []string{base + ".log", base + "_1.log", base + "_2.log", base + "_3.log"}
+	for i, name := range want {
+		got := w.backupName(now)
+		if got != name {
+			t.Fatalf("backup #%v = %v, want %v", i, got, name)
+		}
+		// 已压缩的旧文件同样占用名称
+		if i == 1 {
+			name += ".gz"
+		}
+		writeFile(t, name, "x")
+	}
+	// 同一毫秒的序号排在后面 按名称排序仍然是最新的在前
+	backups := w.backups()
+	if len(backups) != 4 || backups[0] != base+"_3.log" || backups[3] != base+".log" {
+		t.Errorf("backups = %v", backups)
+	}
+}
+
+func TestRotateKeepsBackupsOfSameMillisecond(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := newRotateWriter(config.LogFile{Path: path, MaxSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.file.Close()
+	line := strings.Repeat("x", 600<<10)
+	for i := 0; i < 4; i++ {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 后台清理可能还在运行 等待完成
+	w.cleanMu.Lock()
+	defer w.cleanMu.Unlock()
+	if got := len(w.backups()); got != 3 {
+		t.Errorf("backups = %v, want 3", got)
+	}
+}
+
+func TestOpenResumesStartTime(t *testing.T) {
+	hour := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	tests := []struct {
+		name    string
+		backup  string // 最新的旧文件 为空表示没有
+		modTime time.Time
+		want    time.Time
+	}{
+		{"from newest backup", "app-" + hour.Format(backupTimeFormat) + "_1.log.gz", time.Now().Add(-time.Minute), hour},
+		{"from mod time", "", hour, hour},
+		{"backup newer than file", "app-" + time.Now().Format(backupTimeFormat) + ".log", hour, hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.log")
+			writeFile(t, path, "old\n")
+			if tt.backup != "" {
+				writeFile(t, filepath.Join(dir, "app-"+hour.Add(-time.Hour).Format(backupTimeFormat)+".log"), "x")
+				writeFile(t, filepath.Join(dir, tt.backup), "x")
+			}
+			if err := os.Chtimes(path, tt.modTime, tt.modTime); err != nil {
+				t.Fatal(err)
+			}
+			w, err := newRotateWriter(config.LogFile{Path: path, RotateInterval: 30 * time.Minute})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.file.Close()
+			if !w.openedAt.Equal(tt.want) {
+				t.Errorf("openedAt = %v, want %v", w.openedAt, tt.want)
+			}
+			if !w.shouldRotate(1) {
+				t.Errorf("file opened an hour ago should rotate")
+			}
+		})
+	}
+
+	// 新文件和轮转后的文件从现在开始计时
+	dir := t.TempDir()
+	w, err := newRotateWriter(config.LogFile{Path: filepath.Join(dir, "app.log"), RotateInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.file.Close()
+	if time.Since(w.openedAt) > time.Minute {
+		t.Errorf("new file openedAt = %v", w.openedAt)
+	}
+	for i := 0; i < 2; i++ {
+		w.openedAt = hour
+		if _, err := w.Write([]byte("a\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if time.Since(w.openedAt) > time.Minute || w.shouldRotate(1) {
+		t.Errorf("rotated file openedAt = %v", w.openedAt)
+	}
+}
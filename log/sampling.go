@@ -0,0 +1,182 @@
+package log
+
+import (
+	"github.com/watora/telemetry/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"sync"
+	"time"
+)
+
+const samplingSummaryMessage = "log sampling dropped duplicate records"
+
+// 一个周期内相同级别和消息的计数
+type sampleCounter struct {
+	level    string
+	message  string
+	start    time.Time
+	interval time.Duration
+	n        int64
+	dropped  int64
+}
+
+// sampler 按级别和消息采样 周期结束时通过report输出被丢弃的条数
+// 有丢弃时启动timer 之后没有新日志也能按时汇报
+type sampler struct {
+	rules     map[string]config.LogSampling
+	report    func(level string, message string, dropped int64)
+	mu        sync.Mutex
+	counters  map[string]*sampleCounter
+	lastSweep time.Time
+	timer     *time.Timer
+	deadline  time.Time
+}
+
+// 没有配置采样时返回nil 不做任何处理
+func newSampler(rules map[string]config.LogSampling, report func(level string, message string, dropped int64)) *sampler {
+	if len(rules) == 0 {
+		return nil
+	}
+	return &sampler{
+		rules:     rules,
+		report:    report,
+		counters:  map[string]*sampleCounter{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *sampler) rule(level string) (config.LogSampling, bool) {
+	if rule, ok := s.rules[level]; ok {
+		return rule, true
+	}
+	rule, ok := s.rules["*"]
+	return rule, ok
+}
+
+// 判断这条日志是否输出
+func (s *sampler) allow(level string, message string) bool {
+	if s == nil {
+		return true
+	}
+	rule, ok := s.rule(level)
+	if !ok {
+		return true
+	}
+	interval := rule.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	now := time.Now()
+	s.mu.Lock()
+	// 定期清理过期的计数 同时汇报上个周期丢弃的条数
+	var expired []*sampleCounter
+	if now.Sub(s.lastSweep) >= time.Second {
+		expired = s.sweep(now)
+		s.lastSweep = now
+	}
+	key := level + "\x00" + message
+	c := s.counters[key]
+	if c == nil || now.Sub(c.start) >= c.interval {
+		if c != nil && c.dropped > 0 {
+			expired = append(expired, c)
+		}
+		c = &sampleCounter{level: level, message: message, start: now, interval: interval}
+		s.counters[key] = c
+	}
+	c.n++
+	first := int64(rule.First)
+	allowed := c.n <= first || (rule.Thereafter > 0 && (c.n-first)%int64(rule.Thereafter) == 0)
+	if !allowed {
+		c.dropped++
+		s.schedule(now, c.start.Add(c.interval))
+	}
+	s.mu.Unlock()
+	for _, e := range expired {
+		s.report(e.level, e.message, e.dropped)
+	}
+	return allowed
+}
+
+// 在at时刻汇报过期的计数 已经有更早的timer时不用处理 调用时需持有锁
+func (s *sampler) schedule(now time.Time, at time.Time) {
+	if s.timer != nil && !at.Before(s.deadline) {
+		return
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.deadline = at
+	s.timer = time.AfterFunc(at.Sub(now), s.flush)
+}
+
+// timer触发 汇报过期的计数 还有丢弃的计数时按最早的过期时间继续等待
+func (s *sampler) flush() {
+	now := time.Now()
+	s.mu.Lock()
+	expired := s.sweep(now)
+	s.lastSweep = now
+	s.timer = nil
+	var next time.Time
+	for _, c := range s.counters {
+		if end := c.start.Add(c.interval); c.dropped > 0 && (next.IsZero() || end.Before(next)) {
+			next = end
+		}
+	}
+	if !next.IsZero() {
+		s.schedule(now, next)
+	}
+	s.mu.Unlock()
+	for _, e := range expired {
+		s.report(e.level, e.message, e.dropped)
+	}
+}
+
+func (s *sampler) sweep(now time.Time) []*sampleCounter {
+	var expired []*sampleCounter
+	for key, c := range s.counters {
+		if now.Sub(c.start) < c.interval {
+			continue
+		}
+		if c.dropped > 0 {
+			expired = append(expired, c)
+		}
+		delete(s.counters, key)
+	}
+	return expired
+}
+
+// samplingCore 对zap日志采样 汇总记录直接写到内部的core
+type samplingCore struct {
+	zapcore.Core
+	sampler *sampler
+}
+
+// 没有配置采样时返回原来的core
+func newSamplingCore(core zapcore.Core) zapcore.Core {
+	s := newSampler(config.Global.LogSampling, func(level string, message string, dropped int64) {
+		ent := zapcore.Entry{Level: zapcore.WarnLevel, Time: time.Now(), Message: samplingSummaryMessage}
+		if ce := core.Check(ent, nil); ce != nil {
+			ce.Write(
+				zap.String("sampled_level", level),
+				zap.String("sampled_message", message),
+				zap.Int64("dropped", dropped),
+				zap.String("env", config.Global.Env),
+			)
+		}
+	})
+	if s == nil {
+		return core
+	}
+	return &samplingCore{Core: core, sampler: s}
+}
+
+func (c *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &samplingCore{Core: c.Core.With(fields), sampler: c.sampler}
+}
+
+func (c *samplingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.sampler.allow(ent.Level.String(), ent.Message) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
@@ -0,0 +1,92 @@
+package log
+
+import (
+	"github.com/watora/telemetry/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+type samplingReport struct {
+	level   string
+	message string
+	dropped int64
+}
+
+type samplingRecorder struct {
+	mu      sync.Mutex
+	reports []samplingReport
+}
+
+func (r *samplingRecorder) report(level string, message string, dropped int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports = append(r.reports, samplingReport{level, message, dropped})
+}
+
+func (r *samplingRecorder) get() []samplingReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]samplingReport{}, r.reports...)
+}
+
+func TestSamplerAllow(t *testing.T) {
+	rec := &samplingRecorder{}
+	s := newSampler(map[string]config.LogSampling{
+		"info": {Interval: time.Hour, First: 2, Thereafter: 3},
+		"*":    {Interval: time.Hour, First: 1},
+	}, rec.report)
+	var got []bool
+	for i := 0; i < 8; i++ {
+		got = append(got, s.allow("info", "m"))
+	}
+	want := []bool{true, true, false, false, true, false, false, true}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("info #%v allowed = %v, want %v", i+1, got[i], want[i])
+		}
+	}
+	if !s.allow("warn", "m") || s.allow("warn", "m") {
+		t.Errorf("warn should allow only the first record")
+	}
+	if !s.allow("warn", "other") {
+		t.Errorf("different message should be counted separately")
+	}
+	if newSampler(nil, rec.report).allow("info", "m") != true {
+		t.Errorf("nil sampler should allow everything")
+	}
+}
+
+// 周期结束后没有新日志也要汇报丢弃的条数
+func TestSamplerReportsWithoutTraffic(t *testing.T) {
+	rec := &samplingRecorder{}
+	s := newSampler(map[string]config.LogSampling{
+		"info":  {Interval: 50 * time.Millisecond, First: 1},
+		"error": {Interval: time.Hour, First: 1},
+	}, rec.report)
+	for i := 0; i < 4; i++ {
+		s.allow("info", "m")
+	}
+	// 周期更长的计数不影响短周期的汇报
+	s.allow("error", "e")
+	s.allow("error", "e")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(rec.get()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	reports := rec.get()
+	if len(reports) != 1 || reports[0] != (samplingReport{"info", "m", 3}) {
+		t.Fatalf("reports = %+v", reports)
+	}
+	s.mu.Lock()
+	_, ok := s.counters["info\x00m"]
+	pending := s.timer != nil
+	s.mu.Unlock()
+	if ok {
+		t.Errorf("expired counter not removed")
+	}
+	if !pending {
+		t.Errorf("timer for the remaining counter not scheduled")
+	}
+}
@@ -0,0 +1,207 @@
+package log
+
+import (
+	"context"
+	"github.com/watora/telemetry/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SlogHandler 把slog的日志写到zap core 和initLogger一样输出到collector和stderr
+// 使用相同的env字段 日志级别 trace字段和caller
+type SlogHandler struct {
+	core zapcore.Core
+	name string
+	// WithGroup之后的属性要和日志本身的属性放到同一个group里 先暂存
+	groups []string
+	attrs  [][]slog.Attr
+}
+
+// NewSlogHandler 基于指定的zap logger创建slog.Handler 级别覆盖按logger的名称生效
+func NewSlogHandler(logger *zap.Logger) *SlogHandler {
+	return &SlogHandler{core: logger.Core(), name: logger.Name()}
+}
+
+// SetSlogDefault 把slog的默认logger替换为写入默认logger的handler
+func SetSlogDefault() {
+	logger := defaultLogger
+	if !config.Global.UseLogger {
+		logger = getDevLogger()
+	}
+	slog.SetDefault(slog.New(NewSlogHandler(logger)))
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.core.Enabled(zapLevel(level))
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	ent := zapcore.Entry{
+		LoggerName: h.name,
+		Time:       r.Time,
+		Level:      zapLevel(r.Level),
+		Message:    r.Message,
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ent.Caller = zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true)
+		ent.Caller.Function = frame.Function
+	}
+	ce := h.core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+	// 和initLogger的zap.AddStacktrace(zapcore.ErrorLevel)一致
+	if ent.Level >= zapcore.ErrorLevel {
+		ce.Entry.Stack = stacktrace(ent.Caller)
+	}
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	// 从最内层的group开始组装 h.attrs可能被并发的Handle共用 不能直接append
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		attrs = append(slices.Clone(h.attrs[i]), attrs...)
+		attrs = []slog.Attr{{Key: h.groups[i], Value: slog.GroupValue(attrs...)}}
+	}
+	fields := make([]zap.Field, 0, len(attrs)+4)
+	for _, attr := range attrs {
+		fields = appendAttr(fields, attr)
+	}
+	if ctx != nil {
+		fields = append(fields, ctxFields(ctx)...)
+	}
+	ce.Write(fields...)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := *h
+	if len(h.groups) == 0 {
+		fields := make([]zap.Field, 0, len(attrs))
+		for _, attr := range attrs {
+			fields = appendAttr(fields, attr)
+		}
+		clone.core = h.core.With(fields)
+		return &clone
+	}
+	last := len(h.attrs) - 1
+	clone.attrs = append([][]slog.Attr{}, h.attrs...)
+	clone.attrs[last] = append(append([]slog.Attr{}, h.attrs[last]...), attrs...)
+	return &clone
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.groups = append(append([]string{}, h.groups...), name)
+	clone.attrs = append(append([][]slog.Attr{}, h.attrs...), nil)
+	return &clone
+}
+
+// 从调用slog的位置开始的调用栈 格式和zap的stacktrace相同
+func stacktrace(caller zapcore.EntryCaller) string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	var b strings.Builder
+	// 跳过slog内部的调用 没有caller时从Handle的调用方开始
+	found := !caller.Defined
+	for {
+		frame, more := frames.Next()
+		if !found && frame.Function == caller.Function && frame.Line == caller.Line {
+			found = true
+		}
+		if found {
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(frame.Function)
+			b.WriteString("\n\t")
+			b.WriteString(frame.File)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(frame.Line))
+		}
+		if !more {
+			return b.String()
+		}
+	}
+}
+
+func zapLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zapcore.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zapcore.WarnLevel
+	case level >= slog.LevelInfo:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+// slog的属性转成zap字段 group转成嵌套的对象
+func appendAttr(fields []zap.Field, attr slog.Attr) []zap.Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return append(fields, zap.String(attr.Key, attr.Value.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(attr.Key, attr.Value.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(attr.Key, attr.Value.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(attr.Key, attr.Value.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(attr.Key, attr.Value.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(attr.Key, attr.Value.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(attr.Key, attr.Value.Time()))
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		if len(group) == 0 {
+			return fields
+		}
+		// 没有key的group直接展开到上一层
+		if attr.Key == "" {
+			for _, a := range group {
+				fields = appendAttr(fields, a)
+			}
+			return fields
+		}
+		return append(fields, zap.Object(attr.Key, slogGroup(group)))
+	default:
+		if err, ok := attr.Value.Any().(error); ok {
+			return append(fields, zap.NamedError(attr.Key, err))
+		}
+		return append(fields, zap.Any(attr.Key, attr.Value.Any()))
+	}
+}
+
+type slogGroup []slog.Attr
+
+func (g slogGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	fields := make([]zap.Field, 0, len(g))
+	for _, attr := range g {
+		fields = appendAttr(fields, attr)
+	}
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+	return nil
+}
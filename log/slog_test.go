@@ -0,0 +1,130 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newObservedSlog() (*slog.Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return slog.New(NewSlogHandler(zap.New(core))), logs
+}
+
+func TestSlogHandlerAttrsAndGroups(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l *slog.Logger)
+		want map[string]any
+	}{
+		{
+			name: "attrs",
+			log:  func(l *slog.Logger) { l.With("a", 1).Info("m", "b", "x") },
+			want: map[string]any{"a": int64(1), "b": "x"},
+		},
+		{
+			name: "group",
+			log:  func(l *slog.Logger) { l.With("a", 1).WithGroup("g").With("b", 2).Info("m", "c", 3) },
+			want: map[string]any{"a": int64(1), "g": map[string]any{"b": int64(2), "c": int64(3)}},
+		},
+		{
+			name: "nested groups",
+			log: func(l *slog.Logger) {
+				l.WithGroup("g").With("b", 2).WithGroup("h").With("x", true).Info("m", "c", 3)
+			},
+			want: map[string]any{"g": map[string]any{"b": int64(2), "h": map[string]any{"x": true, "c": int64(3)}}},
+		},
+		{
+			name: "inline and empty group",
+			log: func(l *slog.Logger) {
+				l.Info("m", slog.Group("", "a", 1), slog.Group("empty"), slog.Group("g", "b", "y"))
+			},
+			want: map[string]any{"a": int64(1), "g": map[string]any{"b": "y"}},
+		},
+		{
+			name: "empty group name",
+			log:  func(l *slog.Logger) { l.WithGroup("").With("a", 1).Info("m") },
+			want: map[string]any{"a": int64(1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, logs := newObservedSlog()
+			tt.log(l)
+			entries := logs.AllUntimed()
+			if len(entries) != 1 {
+				t.Fatalf("entries = %v, want 1", len(entries))
+			}
+			if got := entries[0].ContextMap(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlogHandlerLevel(t *testing.T) {
+	l, logs := newObservedSlog()
+	l.Debug("debug")
+	l.Log(context.Background(), slog.LevelWarn+1, "warn")
+	l.Error("error")
+	want := []zapcore.Level{zapcore.DebugLevel, zapcore.WarnLevel, zapcore.ErrorLevel}
+	entries := logs.AllUntimed()
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %v, want %v", len(entries), len(want))
+	}
+	for i, e := range entries {
+		if e.Level != want[i] {
+			t.Errorf("%v level = %v, want %v", e.Message, e.Level, want[i])
+		}
+	}
+}
+
+// 同一个带group的logger被并发使用 各条日志的属性不能互相覆盖 需要用-race运行
+func TestSlogHandlerConcurrentGroups(t *testing.T) {
+	l, logs := newObservedSlog()
+	// 多次With后暂存的属性切片容量大于长度
+	l = l.WithGroup("g").With("a", 1, "b", 2, "c", 3).With("d", 4)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l.Info(fmt.Sprint(i), "i", i)
+		}(i)
+	}
+	wg.Wait()
+	entries := logs.AllUntimed()
+	if len(entries) != 50 {
+		t.Fatalf("entries = %v, want 50", len(entries))
+	}
+	for _, e := range entries {
+		g, _ := e.ContextMap()["g"].(map[string]any)
+		if got := fmt.Sprint(g["i"]); got != e.Message || len(g) != 5 {
+			t.Errorf("entry %v has group %v", e.Message, g)
+		}
+	}
+}
+
+func TestSlogHandlerStacktrace(t *testing.T) {
+	l, logs := newObservedSlog()
+	l.Warn("warn")
+	l.Error("error")
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("entries = %v, want 2", len(entries))
+	}
+	if entries[0].Stack != "" {
+		t.Errorf("warn has stacktrace %q", entries[0].Stack)
+	}
+	// 调用栈从调用slog的函数开始
+	if !strings.HasPrefix(entries[1].Stack, "github.com/watora/telemetry/log.TestSlogHandlerStacktrace\n") {
+		t.Errorf("error stacktrace = %q", entries[1].Stack)
+	}
+}
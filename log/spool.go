@@ -0,0 +1,199 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/internal/health"
+	"github.com/watora/telemetry/internal/spool"
+	collogpb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 代理处理一个请求(重放和转发)的总时长 比exporter的超时短
+// exporter超时重试前代理已经转发或落盘完成 不会同时存在两份相同的数据
+const proxyTimeout = logExportTimeout * 4 / 5
+
+var logSpool *spool.Spool
+var proxyOnce sync.Once
+var proxyURL *url.URL
+var proxyErr error
+
+// 落盘的请求
+type spooledRequest struct {
+	URL     string      `json:"url"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+	Created time.Time   `json:"created"`
+}
+
+// 本地代理 otlploghttp不支持自定义client 通过WithProxy把请求转到这里
+// 转发失败时把请求落盘并返回成功 恢复后先按顺序重放落盘的请求
+// 收集器收到数据(包括重放)时记为导出成功 落盘的只记为spooled
+// 只转发到配置的LogEndPoint 避免被本机其他进程当作开放代理
+type spoolProxy struct {
+	spool   *spool.Spool
+	client  *http.Client
+	target  string
+	timeout time.Duration
+}
+
+// 启动本地代理 多个provider共用
+func startSpoolProxy() (*url.URL, error) {
+	proxyOnce.Do(func() {
+		logSpool, proxyErr = spool.New(filepath.Join(config.Global.SpoolDir, "logs"), config.SpoolMaxBytes())
+		if proxyErr != nil {
+			return
+		}
+		health.SetQueue(health.Logs, func() (int64, int64) {
+			return int64(logSpool.Len()), logSpool.Dropped()
+		})
+		var listener net.Listener
+		listener, proxyErr = net.Listen("tcp", "127.0.0.1:0")
+		if proxyErr != nil {
+			return
+		}
+		proxy := &spoolProxy{
+			spool:   logSpool,
+			client:  &http.Client{},
+			target:  config.Global.LogEndPoint,
+			timeout: proxyTimeout,
+		}
+		go func() {
+			_ = http.Serve(listener, proxy)
+		}()
+		proxyURL = &url.URL{Scheme: "http", Host: listener.Addr().String()}
+	})
+	return proxyURL, proxyErr
+}
+
+func (p *spoolProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.allowed(r.URL.String()) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &spooledRequest{
+		URL:     r.URL.String(),
+		Header:  r.Header.Clone(),
+		Body:    body,
+		Created: time.Now(),
+	}
+	// 去掉发给代理的header
+	req.Header.Del("Proxy-Connection")
+	req.Header.Del("Proxy-Authorization")
+	req.Header.Del("Content-Length")
+
+	// 不使用r.Context() exporter断开时也要处理完 落盘或转发
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	// 先重放落盘的请求 没重放完时直接落盘 保证顺序
+	err = p.spool.Drain(func(data []byte) error {
+		spooled := &spooledRequest{}
+		if err := json.Unmarshal(data, spooled); err != nil || !p.allowed(spooled.URL) {
+			p.spool.Drop()
+			return nil
+		}
+		resp, err := p.forward(ctx, spooled)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if retryableStatus(resp.StatusCode) {
+			return fmt.Errorf("collector responded %v", resp.StatusCode)
+		}
+		if resp.StatusCode >= 300 {
+			// 不可重试的请求丢掉 避免阻塞队列
+			p.spool.Drop()
+			return nil
+		}
+		health.Export(health.Logs, logRecords(spooled.Body), nil)
+		return nil
+	})
+	if err == nil {
+		var resp *http.Response
+		resp, err = p.forward(ctx, req)
+		if err == nil {
+			defer resp.Body.Close()
+			if !retryableStatus(resp.StatusCode) {
+				// 失败的状态码由exporter返回错误 healthExporter记录
+				if resp.StatusCode < 300 {
+					health.Export(health.Logs, logRecords(body), nil)
+				}
+				for key, values := range resp.Header {
+					w.Header()[key] = values
+				}
+				w.WriteHeader(resp.StatusCode)
+				_, _ = io.Copy(w, resp.Body)
+				return
+			}
+		}
+	}
+	data, _ := json.Marshal(req)
+	if pErr := p.spool.Push(data); pErr != nil {
+		http.Error(w, pErr.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	health.Spool(health.Logs, logRecords(body))
+	// 已落盘 告诉exporter导出成功 空body是合法的响应
+	w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+	w.WriteHeader(http.StatusOK)
+}
+
+// 只允许http POST到LogEndPoint
+func (p *spoolProxy) allowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host == p.target
+}
+
+func (p *spoolProxy) forward(ctx context.Context, req *spooledRequest) (*http.Response, error) {
+	if !p.allowed(req.URL) {
+		return nil, fmt.Errorf("spooled request to %v is not the log endpoint", req.URL)
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header = req.Header.Clone()
+	return p.client.Do(r)
+}
+
+// otlploghttp发送的protobuf请求中的日志条数 无法解析时为0
+func logRecords(body []byte) int {
+	req := &collogpb.ExportLogsServiceRequest{}
+	if err := proto.Unmarshal(body, req); err != nil {
+		return 0
+	}
+	n := 0
+	for _, rl := range req.GetResourceLogs() {
+		for _, sl := range rl.GetScopeLogs() {
+			n += len(sl.GetLogRecords())
+		}
+	}
+	return n
+}
+
+// 收集器不可用时的状态码
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package log
+
+import (
+	"bytes"
+	"github.com/watora/telemetry/internal/health"
+	"github.com/watora/telemetry/internal/spool"
+	collogpb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logpb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 记录收到的请求 status决定返回的状态码
+type stubCollector struct {
+	mu     sync.Mutex
+	status int
+	delay  time.Duration
+	bodies []string
+}
+
+func (c *stubCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	delay := c.delay
+	c.mu.Unlock()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.status < 300 {
+		c.bodies = append(c.bodies, string(body))
+	}
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+	}
+}
+
+func (c *stubCollector) setStatus(status int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status = status
+}
+
+func (c *stubCollector) received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.bodies...)
+}
+
+func newTestProxy(t *testing.T, dir string, maxBytes int64) (*spoolProxy, *stubCollector, string) {
+	t.Helper()
+	collector := &stubCollector{}
+	srv := httptest.NewServer(collector)
+	t.Cleanup(srv.Close)
+	s, err := spool.New(dir, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(srv.URL)
+	return &spoolProxy{spool: s, client: srv.Client(), target: u.Host, timeout: proxyTimeout}, collector, srv.URL + "/v1/logs"
+}
+
+// n条日志的otlp请求 body中带上name便于区分
+func logsBody(name string, n int) []byte {
+	records := make([]*logpb.LogRecord, n)
+	for i := range records {
+		records[i] = &logpb.LogRecord{SeverityText: name}
+	}
+	data, _ := proto.Marshal(&collogpb.ExportLogsServiceRequest{
+		ResourceLogs: []*logpb.ResourceLogs{{ScopeLogs: []*logpb.ScopeLogs{{LogRecords: records}}}},
+	})
+	return data
+}
+
+func send(t *testing.T, p *spoolProxy, target string, body []byte) int {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestSpoolProxyHealth(t *testing.T) {
+	p, collector, target := newTestProxy(t, t.TempDir(), 1<<20)
+	before := health.Snapshot()[health.Logs]
+
+	// 收集器不可用 请求落盘 exporter收到200 但不算导出成功
+	collector.setStatus(http.StatusServiceUnavailable)
+	for i := 0; i < 2; i++ {
+		if code := send(t, p, target, logsBody("down", 3)); code != http.StatusOK {
+			t.Fatalf("status = %v, want 200", code)
+		}
+	}
+	status := health.Snapshot()[health.Logs]
+	if status.Exported != before.Exported || !status.LastSuccess.Equal(before.LastSuccess) {
+		t.Errorf("spooled exports counted as success: %+v", status)
+	}
+	if got := status.Spooled - before.Spooled; got != 6 {
+		t.Errorf("spooled = %v, want 6", got)
+	}
+
+	// 恢复后重放的和当前的数据都算成功
+	collector.setStatus(0)
+	if code := send(t, p, target, logsBody("up", 2)); code != http.StatusOK {
+		t.Fatalf("status = %v, want 200", code)
+	}
+	status = health.Snapshot()[health.Logs]
+	if got := status.Exported - before.Exported; got != 8 {
+		t.Errorf("exported = %v, want 8", got)
+	}
+	if !status.LastSuccess.After(before.LastSuccess) {
+		t.Errorf("last success not updated")
+	}
+	if got := len(collector.received()); got != 3 {
+		t.Errorf("collector received %v requests, want 3", got)
+	}
+}
+
+func TestProxyTimeoutShorterThanExporter(t *testing.T) {
+	if proxyTimeout >= logExportTimeout {
+		t.Fatalf("proxy timeout %v must be shorter than exporter timeout %v", proxyTimeout, logExportTimeout)
+	}
+	p, collector, target := newTestProxy(t, t.TempDir(), 1<<20)
+	p.timeout = 100 * time.Millisecond
+	collector.mu.Lock()
+	collector.delay = time.Second
+	collector.mu.Unlock()
+
+	// 收集器响应太慢时在超时内落盘并返回
+	start := time.Now()
+	if code := send(t, p, target, logsBody("slow", 1)); code != http.StatusOK {
+		t.Fatalf("status = %v, want 200", code)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("proxy took %v, want about %v", elapsed, p.timeout)
+	}
+	if p.spool.Len() != 1 {
+		t.Errorf("spool len = %v, want 1", p.spool.Len())
+	}
+}
+
+// 收到的请求中的日志 SeverityText是logsBody的name
+func receivedNames(t *testing.T, c *stubCollector) []string {
+	t.Helper()
+	var names []string
+	for _, body := range c.received() {
+		req := &collogpb.ExportLogsServiceRequest{}
+		if err := proto.Unmarshal([]byte(body), req); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, req.GetResourceLogs()[0].GetScopeLogs()[0].GetLogRecords()[0].GetSeverityText())
+	}
+	return names
+}
+
+func TestSpoolProxyReplayBeforeLive(t *testing.T) {
+	dir := t.TempDir()
+	p, collector, target := newTestProxy(t, dir, 1<<20)
+	collector.setStatus(http.StatusServiceUnavailable)
+	send(t, p, target, logsBody("a", 1))
+	send(t, p, target, logsBody("b", 1))
+
+	// 重启后重新打开 落盘的请求在当前数据之前重放
+	s, err := spool.New(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 2 {
+		t.Fatalf("spool len after reopen = %v, want 2", s.Len())
+	}
+	p.spool = s
+	collector.setStatus(0)
+	if code := send(t, p, target, logsBody("c", 1)); code != http.StatusOK {
+		t.Fatalf("status = %v, want 200", code)
+	}
+	if got := receivedNames(t, collector); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("collector received %v", got)
+	}
+	if s.Len() != 0 {
+		t.Errorf("spool len = %v, want 0", s.Len())
+	}
+}
+
+func TestSpoolProxyDropsNonRetryable(t *testing.T) {
+	p, collector, target := newTestProxy(t, t.TempDir(), 1<<20)
+	collector.setStatus(http.StatusServiceUnavailable)
+	send(t, p, target, logsBody("a", 1))
+
+	// 重放时收集器拒绝的请求丢掉 当前请求的错误返回给exporter
+	collector.setStatus(http.StatusBadRequest)
+	if code := send(t, p, target, logsBody("b", 1)); code != http.StatusBadRequest {
+		t.Errorf("status = %v, want 400", code)
+	}
+	if p.spool.Len() != 0 || p.spool.Dropped() != 1 {
+		t.Errorf("spool len = %v dropped = %v, want 0 1", p.spool.Len(), p.spool.Dropped())
+	}
+
+	collector.setStatus(0)
+	send(t, p, target, logsBody("c", 1))
+	if got := receivedNames(t, collector); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("collector received %v", got)
+	}
+}
+
+func TestSpoolProxyForbidden(t *testing.T) {
+	p, collector, _ := newTestProxy(t, t.TempDir(), 1<<20)
+	if code := send(t, p, "http://example.com/v1/logs", logsBody("a", 1)); code != http.StatusForbidden {
+		t.Errorf("status = %v, want 403", code)
+	}
+	if p.spool.Len() != 0 || len(collector.received()) != 0 {
+		t.Errorf("forbidden request was spooled or forwarded")
+	}
+}
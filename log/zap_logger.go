@@ -5,34 +5,46 @@ import (
 	"github.com/watora/telemetry/config"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"sync"
 )
 
 var devLogger = zap.NewExample()
+var devOnce sync.Once
+
+// 没有开启UseLogger时使用的logger 开发模式下输出带颜色的文本
+func getDevLogger() *zap.Logger {
+	devOnce.Do(func() {
+		if config.Global.DevMode {
+			devLogger = zap.New(newStdCore(zapcore.DebugLevel), zap.AddCaller())
+		}
+	})
+	return devLogger
+}
 
 // WithCtx 要带traceId的话需要先调这个
 func WithCtx(logger *zap.Logger, ctx context.Context) *zap.Logger {
 	if !config.Global.UseLogger {
 		return logger
 	}
-	return logger.With(zap.Any("context", ctx))
+	return logger.With(ctxFields(ctx)...)
 }
 
 // WithCtxDefault 使用默认logger
 func WithCtxDefault(ctx context.Context) *zap.Logger {
 	if !config.Global.UseLogger {
-		return devLogger
+		return getDevLogger()
 	}
-	return defaultLogger.With(zap.Any("context", ctx))
+	return defaultLogger.With(ctxFields(ctx)...)
 }
 
 // 全局方法
 func ctxLog(ctx context.Context, level zapcore.Level, message string, fields ...zap.Field) {
 	if !config.Global.UseLogger {
-		devLogger.Log(level, message, fields...)
+		getDevLogger().WithOptions(zap.AddCallerSkip(2)).Log(level, message, fields...)
 		return
 	}
 	// 传context可以自动取traceId
-	fields = append(fields, zap.Any("context", ctx))
+	fields = append(fields, ctxFields(ctx)...)
 	defaultLogger.WithOptions(zap.AddCallerSkip(2)).Log(level, message, fields...)
 }
 
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"github.com/watora/telemetry/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	api "go.opentelemetry.io/otel/metric"
+	stdlog "log"
+	"path"
+	"sync"
+	"time"
+)
+
+// 超限后非公共属性的值统一替换为other
+const overflowValue = "other"
+
+var limiterMap sync.Map // *seriesLimiter
+
+// 公共属性 超限后保留原值
+var commonKeys = map[attribute.Key]struct{}{
+	"env":          {},
+	"version":      {},
+	"host":         {},
+	"service.name": {},
+}
+
+// 单个指标的序列数限制
+type seriesLimiter struct {
+	max     int
+	mu      sync.Mutex
+	series  map[attribute.Distinct]struct{}
+	tripped bool
+}
+
+// 按配置取指标的最大序列数 MetricView中的MaxSeries优先
+func maxSeries(name string) int {
+	for _, view := range config.Global.MetricViews {
+		if view.MaxSeries <= 0 {
+			continue
+		}
+		if ok, _ := path.Match(view.Name, name); ok {
+			return view.MaxSeries
+		}
+	}
+	return config.Global.MaxSeries
+}
+
+func getLimiter(name string) *seriesLimiter {
+	if l, ok := limiterMap.Load(name); ok {
+		return l.(*seriesLimiter)
+	}
+	l, _ := limiterMap.LoadOrStore(name, &seriesLimiter{
+		max:    maxSeries(name),
+		series: make(map[attribute.Distinct]struct{}),
+	})
+	return l.(*seriesLimiter)
+}
+
+// 限制指标的序列数 超过上限的新序列归入other
+func limitSeries(ctx context.Context, name string, attr []attribute.KeyValue) attribute.Set {
+	set := attribute.NewSet(attr...)
+	l := getLimiter(name)
+	if l.max <= 0 {
+		return set
+	}
+	key := set.Equivalent()
+	l.mu.Lock()
+	if _, ok := l.series[key]; ok || len(l.series) < l.max {
+		l.series[key] = struct{}{}
+		l.mu.Unlock()
+		return set
+	}
+	first := !l.tripped
+	l.tripped = true
+	l.mu.Unlock()
+
+	if first {
+		warn(ctx, fmt.Sprintf("metric %v exceeds %v series, new series are recorded as %v", name, l.max, overflowValue),
+			log.String("metric", name), log.Int("max_series", l.max))
+	}
+	if counter, err := getCounter("metric_overflow"); err == nil {
+		counter.Add(ctx, 1, api.WithAttributes(fillCommonAttr([]attribute.KeyValue{attribute.String("metric", name)})...))
+	}
+	overflow := make([]attribute.KeyValue, 0, len(attr))
+	for _, kv := range attr {
+		if _, ok := commonKeys[kv.Key]; !ok {
+			kv.Value = attribute.StringValue(overflowValue)
+		}
+		overflow = append(overflow, kv)
+	}
+	return attribute.NewSet(overflow...)
+}
+
+// 输出告警日志到otel 同时输出到stderr 避免没有开启UseLogger或log.Init之前丢失
+func warn(ctx context.Context, msg string, attrs ...log.KeyValue) {
+	stdlog.Printf("telemetry: %v", msg)
+	r := log.Record{}
+	r.SetTimestamp(time.Now())
+	r.SetBody(log.StringValue(msg))
+	r.SetSeverity(log.SeverityWarn)
+	r.SetSeverityText("warn")
+	r.AddAttributes(attrs...)
+	r.AddAttributes(log.String("env", config.Global.Env))
+	global.GetLoggerProvider().Logger("telemetry_metrics").Emit(ctx, r)
+}
@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/watora/telemetry/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+type Kind string
+
+const (
+	KindCounter           Kind = "counter"
+	KindHistogram         Kind = "histogram"
+	KindGauge             Kind = "gauge"
+	KindUpDown            Kind = "updown"
+	KindObservableCounter Kind = "observable_counter"
+	KindObservableGauge   Kind = "observable_gauge"
+	KindObservableUpDown  Kind = "observable_updown"
+)
+
+// Definition 指标声明
+type Definition struct {
+	Name          string   `json:"name"` // 不带AppName前缀
+	Kind          Kind     `json:"kind"`
+	Unit          string   `json:"unit,omitempty"`
+	Description   string   `json:"description,omitempty"`
+	AttributeKeys []string `json:"attribute_keys,omitempty"` // 允许的属性 公共属性无需声明
+}
+
+var catalogMap sync.Map // Definition
+var declared atomic.Bool
+var rejectedMap sync.Map // 已经告警过的指标
+
+// 内置仪表化的指标 保持和已有序列一致 不设置unit
+var builtinDefinitions = []Definition{
+	{Name: "http_count", Kind: KindCounter, Description: "Number of http requests.", AttributeKeys: []string{"path", "method", "status_code", "success"}},
+	{Name: "http_duration", Kind: KindHistogram, Description: "Duration of http requests in milliseconds.", AttributeKeys: []string{"path", "method", "status_code", "success"}},
+	{Name: "gorm_count", Kind: KindCounter, Description: "Number of gorm operations.", AttributeKeys: []string{"table", "success", "command", "driver"}},
+	{Name: "gorm_duration", Kind: KindHistogram, Description: "Duration of gorm operations in milliseconds.", AttributeKeys: []string{"table", "success", "command", "driver"}},
+	{Name: "zinx_count", Kind: KindCounter, Description: "Number of zinx requests.", AttributeKeys: []string{"msg_id"}},
+	{Name: "zinx_duration", Kind: KindHistogram, Description: "Duration of zinx requests in milliseconds.", AttributeKeys: []string{"msg_id"}},
+	{Name: "zinx_live", Kind: KindUpDown, Description: "Number of live zinx connections."},
+	{Name: "redis_v8_count", Kind: KindCounter, Description: "Number of redis v8 commands.", AttributeKeys: []string{"cmd"}},
+	{Name: "redis_v8_duration", Kind: KindHistogram, Description: "Duration of redis v8 commands in milliseconds.", AttributeKeys: []string{"cmd"}},
+	{Name: "redis_v6_count", Kind: KindCounter, Description: "Number of redis v6 commands.", AttributeKeys: []string{"cmd"}},
+	{Name: "redis_v6_duration", Kind: KindHistogram, Description: "Duration of redis v6 commands in milliseconds.", AttributeKeys: []string{"cmd"}},
+	{Name: "mongo_count", Kind: KindCounter, Description: "Number of mongo commands.", AttributeKeys: []string{"cmd", "success"}},
+	{Name: "mongo_duration", Kind: KindHistogram, Description: "Duration of mongo commands in milliseconds.", AttributeKeys: []string{"cmd", "success"}},
+	{Name: "gozero_slow_call_count", Kind: KindCounter, Description: "Number of go-zero slow calls.", AttributeKeys: []string{"kind", "caller"}},
+	{Name: "gozero_slow_call_duration", Kind: KindHistogram, Description: "Duration of go-zero slow calls in milliseconds.", AttributeKeys: []string{"kind"}},
+	{Name: "gozero_qps", Kind: KindGauge, Description: "Requests per second reported by go-zero stat.", AttributeKeys: []string{"name"}},
+	{Name: "gozero_drops", Kind: KindCounter, Description: "Number of requests dropped, reported by go-zero stat.", AttributeKeys: []string{"name"}},
+	{Name: "gozero_latency", Kind: KindGauge, Description: "Request latency in milliseconds reported by go-zero stat.", AttributeKeys: []string{"name", "quantile"}},
+	{Name: "gozero_cpu_usage", Kind: KindGauge, Description: "CPU usage in millicores reported by go-zero."},
+	{Name: "gozero_shedding_count", Kind: KindCounter, Description: "Number of requests checked by go-zero load shedding, by result.", AttributeKeys: []string{"name", "result"}},
+	{Name: "gozero_cache_count", Kind: KindCounter, Description: "Number of go-zero cache lookups, by result.", AttributeKeys: []string{"cache", "result"}},
+	{Name: "http.server.request.duration", Kind: KindHistogram, Unit: "s", Description: "Duration of HTTP server requests.", AttributeKeys: []string{"http.request.method", "http.response.status_code", "url.scheme", "error.type"}},
+	{Name: "db.client.operation.duration", Kind: KindHistogram, Unit: "s", Description: "Duration of database client operations.", AttributeKeys: []string{"db.system", "db.operation.name", "db.collection.name", "error.type"}},
+	{Name: "telemetry_exported", Kind: KindObservableCounter, Description: "Number of telemetry items exported, by signal and result.", AttributeKeys: []string{"signal", "result"}},
+	{Name: "telemetry_dropped", Kind: KindObservableCounter, Description: "Number of telemetry items dropped, by signal.", AttributeKeys: []string{"signal"}},
+	{Name: "telemetry_queue_size", Kind: KindObservableGauge, Description: "Number of spooled exports waiting for replay, by signal.", AttributeKeys: []string{"signal"}},
+	{Name: "telemetry_errors", Kind: KindObservableCounter, Description: "Number of errors reported by the OpenTelemetry SDK."},
+	{Name: "metric_overflow", Kind: KindCounter, Description: "Number of measurements recorded into the overflow series.", AttributeKeys: []string{"metric"}},
+}
+
+func init() {
+	for _, def := range builtinDefinitions {
+		catalogMap.Store(def.Name, def)
+	}
+}
+
+// ResetCatalog 清掉声明的指标 只保留内置指标 用于测试之间隔离
+func ResetCatalog() {
+	catalogMap.Range(func(key, value any) bool {
+		catalogMap.Delete(key)
+		return true
+	})
+	rejectedMap.Range(func(key, value any) bool {
+		rejectedMap.Delete(key)
+		return true
+	})
+	for _, def := range builtinDefinitions {
+		catalogMap.Store(def.Name, def)
+	}
+	declared.Store(false)
+}
+
+// Declare 声明指标 需要在第一次上报前调用 声明后非生产环境会拒绝未声明的指标和属性
+func Declare(defs ...Definition) error {
+	for _, def := range defs {
+		if def.Name == "" || def.Kind == "" {
+			return fmt.Errorf("metric name and kind are required: %+v", def)
+		}
+		if old, ok := catalogMap.Load(def.Name); ok && old.(Definition).Kind != def.Kind {
+			return fmt.Errorf("metric %v already declared as %v", def.Name, old.(Definition).Kind)
+		}
+	}
+	for _, def := range defs {
+		catalogMap.Store(def.Name, def)
+	}
+	declared.Store(true)
+	return nil
+}
+
+// Catalog 导出所有声明的指标 按名称排序
+func Catalog() []Definition {
+	var defs []Definition
+	catalogMap.Range(func(key, value any) bool {
+		defs = append(defs, value.(Definition))
+		return true
+	})
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Name < defs[j].Name
+	})
+	return defs
+}
+
+// CatalogJSON 以json导出指标目录
+func CatalogJSON() ([]byte, error) {
+	return json.MarshalIndent(Catalog(), "", "  ")
+}
+
+// 声明的描述和单位 未声明时为空
+func describe(name string) (desc string, unit string) {
+	if def, ok := catalogMap.Load(name); ok {
+		return def.(Definition).Description, def.(Definition).Unit
+	}
+	return "", ""
+}
+
+// 生产环境不校验
+func isProduction() bool {
+	return config.Global.Env == "prod" || config.Global.Env == "production"
+}
+
+// 校验指标是否已声明 属性是否允许 只有调用过Declare且非生产环境才校验 ctx中的属性不校验
+func validate(ctx context.Context, name string, kind Kind, attr []attribute.KeyValue) error {
+	if !declared.Load() || isProduction() {
+		return nil
+	}
+	err := checkDefinition(name, kind, attr)
+	if err != nil {
+		if _, loaded := rejectedMap.LoadOrStore(name, struct{}{}); !loaded {
+			warn(ctx, fmt.Sprintf("metric rejected: %v", err), log.String("metric", name))
+		}
+	}
+	return err
+}
+
+func checkDefinition(name string, kind Kind, attr []attribute.KeyValue) error {
+	v, ok := catalogMap.Load(name)
+	if !ok {
+		return fmt.Errorf("metric %v is not declared", name)
+	}
+	def := v.(Definition)
+	if def.Kind != kind {
+		return fmt.Errorf("metric %v is declared as %v, got %v", name, def.Kind, kind)
+	}
+	for _, kv := range attr {
+		if _, ok := commonKeys[kv.Key]; ok {
+			continue
+		}
+		allowed := false
+		for _, key := range def.AttributeKeys {
+			if string(kv.Key) == key {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("attribute %v is not declared for metric %v", kv.Key, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"github.com/watora/telemetry/internal/console"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"io"
+	"strings"
+	"time"
+)
+
+// 开发模式 每个导出周期在终端打印指标汇总
+type consoleExporter struct {
+	w io.Writer
+}
+
+func (e *consoleExporter) Temporality(kind metric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case metric.InstrumentKindUpDownCounter, metric.InstrumentKindObservableUpDownCounter:
+		return metricdata.CumulativeTemporality
+	}
+	// 汇总展示每个周期内的增量
+	return metricdata.DeltaTemporality
+}
+
+func (e *consoleExporter) Aggregation(kind metric.InstrumentKind) metric.Aggregation {
+	return metric.DefaultAggregationSelector(kind)
+}
+
+func (e *consoleExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%v %v\n", console.Bold("metrics"), console.Dim(time.Now().Format(time.DateTime)))
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					// 周期内没有变化的不展示
+					if dp.Value == 0 {
+						continue
+					}
+					writeLine(b, m.Name, dp.Attributes, fmt.Sprint(dp.Value))
+				}
+			case metricdata.Sum[float64]:
+				for _, dp := range data.DataPoints {
+					if dp.Value == 0 {
+						continue
+					}
+					writeLine(b, m.Name, dp.Attributes, fmt.Sprintf("%.3f", dp.Value))
+				}
+			case metricdata.Gauge[int64]:
+				for _, dp := range data.DataPoints {
+					writeLine(b, m.Name, dp.Attributes, fmt.Sprint(dp.Value))
+				}
+			case metricdata.Gauge[float64]:
+				for _, dp := range data.DataPoints {
+					writeLine(b, m.Name, dp.Attributes, fmt.Sprintf("%.3f", dp.Value))
+				}
+			case metricdata.Histogram[int64]:
+				for _, dp := range data.DataPoints {
+					writeLine(b, m.Name, dp.Attributes, histogramSummary(dp.Count, float64(dp.Sum), dp.Max))
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					writeLine(b, m.Name, dp.Attributes, histogramSummary(dp.Count, dp.Sum, dp.Max))
+				}
+			case metricdata.ExponentialHistogram[int64]:
+				for _, dp := range data.DataPoints {
+					writeLine(b, m.Name, dp.Attributes, histogramSummary(dp.Count, float64(dp.Sum), dp.Max))
+				}
+			}
+		}
+	}
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+func (e *consoleExporter) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+func (e *consoleExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func writeLine(b *strings.Builder, name string, set attribute.Set, value string) {
+	fmt.Fprintf(b, "  %v %v", console.Cyan(name), console.Green(value))
+	for _, kv := range set.ToSlice() {
+		// 公共属性在开发模式下没有意义
+		if _, ok := commonKeys[kv.Key]; ok {
+			continue
+		}
+		fmt.Fprintf(b, " %v", console.Dim(fmt.Sprintf("%v=%v", kv.Key, kv.Value.Emit())))
+	}
+	b.WriteString("\n")
+}
+
+func histogramSummary[N int64 | float64](count uint64, sum float64, max metricdata.Extrema[N]) string {
+	if count == 0 {
+		return "count=0"
+	}
+	res := fmt.Sprintf("count=%v avg=%.3f", count, sum/float64(count))
+	if v, ok := max.Value(); ok {
+		res += fmt.Sprintf(" max=%v", v)
+	}
+	return res
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"github.com/watora/telemetry/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+type attrCtxKey struct{}
+
+// ContextWithAttributes 把属性存到ctx中 之后使用这个ctx上报的指标都会带上 同名属性以后设置的为准
+func ContextWithAttributes(ctx context.Context, attrs ...attribute.KeyValue) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	old := AttributesFromContext(ctx)
+	merged := make([]attribute.KeyValue, 0, len(old)+len(attrs))
+	merged = append(merged, attrs...)
+	for _, kv := range old {
+		if !hasKey(attrs, kv.Key) {
+			merged = append(merged, kv)
+		}
+	}
+	return context.WithValue(ctx, attrCtxKey{}, merged)
+}
+
+// AttributesFromContext 取出ctx中的属性
+func AttributesFromContext(ctx context.Context) []attribute.KeyValue {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(attrCtxKey{}).([]attribute.KeyValue)
+	return attrs
+}
+
+// 合并ctx中的属性和baggage中配置的key 优先级 显式传入 > ContextWithAttributes > baggage
+func fillContextAttr(ctx context.Context, attr []attribute.KeyValue) []attribute.KeyValue {
+	ctxAttr := AttributesFromContext(ctx)
+	if len(ctxAttr) == 0 && len(config.Global.BaggageKeys) == 0 {
+		return attr
+	}
+	merged := make([]attribute.KeyValue, 0, len(attr)+len(ctxAttr))
+	merged = append(merged, attr...)
+	for _, kv := range ctxAttr {
+		if !hasKey(merged, kv.Key) {
+			merged = append(merged, kv)
+		}
+	}
+	if ctx == nil {
+		return merged
+	}
+	bag := baggage.FromContext(ctx)
+	for _, key := range config.Global.BaggageKeys {
+		if member := bag.Member(key); member.Key() != "" && !hasKey(merged, attribute.Key(key)) {
+			merged = append(merged, attribute.String(key, member.Value()))
+		}
+	}
+	return merged
+}
+
+func hasKey(attr []attribute.KeyValue, key attribute.Key) bool {
+	for _, kv := range attr {
+		if kv.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// InstrumentInfo 同步仪表的最新值 用于调试
+type InstrumentInfo struct {
+	Name    string    `json:"name"` // 不带AppName前缀
+	Kind    Kind      `json:"kind"`
+	Last    float64   `json:"last"`  // 最后一次上报的值
+	Sum     float64   `json:"sum"`   // 累计值
+	Count   int64     `json:"count"` // 上报次数
+	Updated time.Time `json:"updated"`
+}
+
+var statMap sync.Map // *instrumentStat
+
+type instrumentStat struct {
+	mu   sync.Mutex
+	info InstrumentInfo
+}
+
+// 记录最新值
+func observe(name string, kind Kind, v float64) {
+	s, ok := statMap.Load(name)
+	if !ok {
+		s, _ = statMap.LoadOrStore(name, &instrumentStat{info: InstrumentInfo{Name: name, Kind: kind}})
+	}
+	stat := s.(*instrumentStat)
+	stat.mu.Lock()
+	stat.info.Last = v
+	stat.info.Sum += v
+	stat.info.Count++
+	stat.info.Updated = time.Now()
+	stat.mu.Unlock()
+}
+
+// Instruments 已注册的同步仪表及其最新值 按名称排序
+func Instruments() []InstrumentInfo {
+	var res []InstrumentInfo
+	add := func(kind Kind) func(key, value any) bool {
+		return func(key, value any) bool {
+			info := InstrumentInfo{Name: key.(string), Kind: kind}
+			if s, ok := statMap.Load(key); ok {
+				stat := s.(*instrumentStat)
+				stat.mu.Lock()
+				info = stat.info
+				stat.mu.Unlock()
+			}
+			res = append(res, info)
+			return true
+		}
+	}
+	counterMap.Range(add(KindCounter))
+	timerMap.Range(add(KindHistogram))
+	gaugeMap.Range(add(KindGauge))
+	upDownMap.Range(add(KindUpDown))
+	durationMap.Range(add(KindHistogram))
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"context"
+	"github.com/watora/telemetry/internal/health"
+	"go.opentelemetry.io/otel/attribute"
+	api "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// 记录导出结果
+// 启用落盘时失败的数据会落盘并返回成功 由spoolInterceptor按收集器实际的结果记录成功
+type healthExporter struct {
+	metric.Exporter
+	spooled bool
+}
+
+func (e *healthExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	err := e.Exporter.Export(ctx, rm)
+	if err != nil || !e.spooled {
+		health.Export(health.Metrics, dataPoints(rm), err)
+	}
+	return err
+}
+
+func dataPoints(rm *metricdata.ResourceMetrics) int {
+	n := 0
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Sum[float64]:
+				n += len(data.DataPoints)
+			case metricdata.Gauge[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Gauge[float64]:
+				n += len(data.DataPoints)
+			case metricdata.Histogram[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Histogram[float64]:
+				n += len(data.DataPoints)
+			case metricdata.ExponentialHistogram[int64]:
+				n += len(data.DataPoints)
+			case metricdata.ExponentialHistogram[float64]:
+				n += len(data.DataPoints)
+			}
+		}
+	}
+	return n
+}
+
+// 导出情况的自监控指标
+func startHealthMetrics() error {
+	exported, err := meter.Int64ObservableCounter(instrumentName("telemetry_exported"),
+		api.WithDescription("Number of telemetry items exported, by signal and result."))
+	if err != nil {
+		return err
+	}
+	dropped, err := meter.Int64ObservableCounter(instrumentName("telemetry_dropped"),
+		api.WithDescription("Number of telemetry items dropped, by signal."))
+	if err != nil {
+		return err
+	}
+	queue, err := meter.Int64ObservableGauge(instrumentName("telemetry_queue_size"),
+		api.WithDescription("Number of spooled exports waiting for replay, by signal."))
+	if err != nil {
+		return err
+	}
+	errors, err := meter.Int64ObservableCounter(instrumentName("telemetry_errors"),
+		api.WithDescription("Number of errors reported by the OpenTelemetry SDK."))
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(ctx context.Context, observer api.Observer) error {
+		for signal, status := range health.Snapshot() {
+			attr := fillCommonAttr([]attribute.KeyValue{attribute.String("signal", signal)})
+			observer.ObserveInt64(exported, status.Exported,
+				api.WithAttributes(append(attr, attribute.String("result", "success"))...))
+			observer.ObserveInt64(exported, status.Failed,
+				api.WithAttributes(append(attr, attribute.String("result", "failure"))...))
+			observer.ObserveInt64(exported, status.Spooled,
+				api.WithAttributes(append(attr, attribute.String("result", "spooled"))...))
+			observer.ObserveInt64(dropped, status.Dropped, api.WithAttributes(attr...))
+			observer.ObserveInt64(queue, status.QueueSize, api.WithAttributes(attr...))
+		}
+		count, _, _ := health.Errors()
+		observer.ObserveInt64(errors, count, api.WithAttributes(fillCommonAttr(nil)...))
+		return nil
+	}, exported, dropped, queue, errors)
+	return err
+}
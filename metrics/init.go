@@ -4,40 +4,133 @@ import (
 	"context"
 	"fmt"
 	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/internal/health"
+	"github.com/watora/telemetry/internal/identity"
+	"github.com/watora/telemetry/internal/spool"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	api "go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+	"google.golang.org/grpc"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 )
 
 var meter api.Meter
-var counterMap sync.Map // api.Int64Counter
-var timerMap sync.Map   // api.Int64Histogram
-var gaugeMap sync.Map   // api.Int64Gauge
+var provider *metric.MeterProvider
+var counterMap sync.Map  // api.Int64Counter
+var timerMap sync.Map    // api.Int64Histogram
+var gaugeMap sync.Map    // api.Int64Gauge
+var upDownMap sync.Map   // api.Int64UpDownCounter
+var durationMap sync.Map // api.Float64Histogram
 
 // Init 初始化 通过收集器进行收集
 func Init() {
-	exporter, err := otlpmetricgrpc.New(context.Background(),
+	interval := 14 * time.Second // 默认14s导出一次数据
+	if config.Global.MetricsInterval > 0 {
+		interval = config.Global.MetricsInterval
+	}
+	readerOpts := []metric.PeriodicReaderOption{metric.WithInterval(interval)}
+	if config.Global.MetricsTimeout > 0 {
+		readerOpts = append(readerOpts, metric.WithTimeout(config.Global.MetricsTimeout))
+	}
+	if config.Global.DevMode {
+		InitWithReader(metric.NewPeriodicReader(&healthExporter{Exporter: &consoleExporter{w: os.Stderr}}, readerOpts...))
+		return
+	}
+	if useStatsd() {
+		client, err := newStatsdClient(config.Global.StatsdAddr, config.Global.StatsdMTU, config.Global.MetricsExporter == "dogstatsd")
+		if err != nil {
+			panic(fmt.Sprintf("init statsd: %v", err))
+		}
+		InitWithReader(metric.NewPeriodicReader(&healthExporter{Exporter: &statsdExporter{client: client}}, readerOpts...))
+		statsd = client
+		return
+	}
+	metricSpool = nil
+	opts := []otlpmetricgrpc.Option{
 		otlpmetricgrpc.WithInsecure(),
 		otlpmetricgrpc.WithEndpoint(config.Global.MetricsEndPoint),
-	)
+		otlpmetricgrpc.WithTemporalitySelector(temporalitySelector(config.Global.Temporality, config.Global.KindTemporality)),
+	}
+	if config.Global.SpoolDir != "" {
+		var err error
+		metricSpool, err = spool.New(filepath.Join(config.Global.SpoolDir, "metrics"), config.SpoolMaxBytes())
+		if err != nil {
+			panic(fmt.Sprintf("init spool: %v", err))
+		}
+		health.SetQueue(health.Metrics, func() (int64, int64) {
+			return int64(metricSpool.Len()), metricSpool.Dropped()
+		})
+		opts = append(opts, otlpmetricgrpc.WithDialOption(grpc.WithUnaryInterceptor(spoolInterceptor(metricSpool))))
+	}
+	exporter, err := otlpmetricgrpc.New(context.Background(), opts...)
 	if err != nil {
 		panic(fmt.Sprintf("init exporter: %v", err))
 	}
-	provider := metric.NewMeterProvider(
-		metric.WithReader(metric.NewPeriodicReader(exporter, metric.WithInterval(14*time.Second))), //14s导出一次数据
-		metric.WithView(metric.NewView(
-			metric.Instrument{
-				Kind: metric.InstrumentKindHistogram,
-			},
-			metric.Stream{
-				// 调整桶的精度
-				Aggregation: metric.AggregationExplicitBucketHistogram{
-					Boundaries: []float64{0, 1, 2, 5, 10, 20, 30, 50, 75, 100, 250, 500, 1000, 2500, 5000, 10000},
-				},
-			},
-		)),
+	InitWithReader(metric.NewPeriodicReader(&healthExporter{Exporter: exporter, spooled: metricSpool != nil}, readerOpts...))
+}
+
+// InitWithReader 使用指定的reader初始化 如测试中使用ManualReader
+func InitWithReader(reader metric.Reader) {
+	resetInstruments()
+	statsd = nil
+	res, err := identity.Resource(config.Global.AppName, config.Global.Version)
+	if err != nil {
+		panic(fmt.Sprintf("build resource error: %v", err))
+	}
+	// 关闭旧的provider 停止它的reader和exporter
+	if old := provider; old != nil {
+		go func() {
+			_ = old.Shutdown(context.Background())
+		}()
+	}
+	view, err := buildView(config.Global.MetricViews)
+	if err != nil {
+		panic(fmt.Sprintf("build metric views: %v", err))
+	}
+	provider = metric.NewMeterProvider(
+		metric.WithResource(res),
+		metric.WithReader(reader),
+		metric.WithView(view),
+		metric.WithExemplarFilter(exemplarFilter(config.Global.ExemplarFilter)),
 	)
 	meter = provider.Meter(config.Global.AppName)
+	if err := startHealthMetrics(); err != nil {
+		panic(fmt.Sprintf("init health metrics: %v", err))
+	}
+	if config.Global.RuntimeMetrics {
+		if err := startRuntimeMetrics(); err != nil {
+			panic(fmt.Sprintf("init runtime metrics: %v", err))
+		}
+	}
+	if config.Global.ProcessMetrics {
+		if err := startProcessMetrics(); err != nil {
+			panic(fmt.Sprintf("init process metrics: %v", err))
+		}
+	}
+}
+
+// 重新初始化时清掉旧provider创建的仪表
+func resetInstruments() {
+	for _, m := range []*sync.Map{&counterMap, &timerMap, &gaugeMap, &upDownMap, &durationMap, &statMap, &limiterMap, &rejectedMap} {
+		m.Range(func(key, value any) bool {
+			m.Delete(key)
+			return true
+		})
+	}
+}
+
+// exemplar会带上ctx中span的trace_id和span_id 用于从直方图的桶跳转到对应的trace
+func exemplarFilter(name string) exemplar.Filter {
+	switch name {
+	case "always_on":
+		return exemplar.AlwaysOnFilter
+	case "always_off":
+		return exemplar.AlwaysOffFilter
+	default:
+		return exemplar.TraceBasedFilter
+	}
 }
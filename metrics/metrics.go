@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/internal/redact"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	api "go.opentelemetry.io/otel/metric"
 	"golang.org/x/sync/singleflight"
@@ -38,10 +40,19 @@ func EmitCount(ctx context.Context, name string, incr int64, attr ...attribute.K
 	}
 	counter, err := getCounter(name)
 	if err != nil {
+		otel.Handle(err)
 		return
 	}
-	attr = fillCommonAttr(attr)
-	counter.Add(ctx, incr, api.WithAttributes(attr...))
+	if validate(ctx, name, KindCounter, attr) != nil {
+		return
+	}
+	set := limitSeries(ctx, name, fillCommonAttr(redact.Current().Attributes(fillContextAttr(ctx, attr))))
+	observe(name, KindCounter, float64(incr))
+	if statsd != nil {
+		statsd.count(instrumentName(name), incr, set)
+		return
+	}
+	counter.Add(ctx, incr, api.WithAttributeSet(set))
 }
 
 func getCounter(name string) (api.Int64Counter, error) {
@@ -49,7 +60,8 @@ func getCounter(name string) (api.Int64Counter, error) {
 		counter, ok := counterMap.Load(name)
 		if !ok {
 			var err error
-			counter, err = meter.Int64Counter(fmt.Sprintf("%v_%v", config.Global.AppName, name))
+			desc, unit := describe(name)
+			counter, err = meter.Int64Counter(instrumentName(name), api.WithDescription(desc), api.WithUnit(unit))
 			if err != nil {
 				return nil, err
 			}
@@ -70,10 +82,19 @@ func EmitTime(ctx context.Context, name string, ms int64, attr ...attribute.KeyV
 	}
 	timer, err := getTimer(name)
 	if err != nil {
+		otel.Handle(err)
 		return
 	}
-	attr = fillCommonAttr(attr)
-	timer.Record(ctx, ms, api.WithAttributes(attr...))
+	if validate(ctx, name, KindHistogram, attr) != nil {
+		return
+	}
+	set := limitSeries(ctx, name, fillCommonAttr(redact.Current().Attributes(fillContextAttr(ctx, attr))))
+	observe(name, KindHistogram, float64(ms))
+	if statsd != nil {
+		statsd.timing(instrumentName(name), float64(ms), set)
+		return
+	}
+	timer.Record(ctx, ms, api.WithAttributeSet(set))
 }
 
 func getTimer(name string) (api.Int64Histogram, error) {
@@ -81,7 +102,8 @@ func getTimer(name string) (api.Int64Histogram, error) {
 		timer, ok := timerMap.Load(name)
 		if !ok {
 			var err error
-			timer, err = meter.Int64Histogram(fmt.Sprintf("%v_%v", config.Global.AppName, name))
+			desc, unit := describe(name)
+			timer, err = meter.Int64Histogram(instrumentName(name), api.WithDescription(desc), api.WithUnit(unit))
 			if err != nil {
 				return nil, err
 			}
@@ -102,10 +124,19 @@ func EmitGauge(ctx context.Context, name string, n int64, attr ...attribute.KeyV
 	}
 	gauge, err := getGauge(name)
 	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	if validate(ctx, name, KindGauge, attr) != nil {
+		return
+	}
+	set := limitSeries(ctx, name, fillCommonAttr(redact.Current().Attributes(fillContextAttr(ctx, attr))))
+	observe(name, KindGauge, float64(n))
+	if statsd != nil {
+		statsd.gauge(instrumentName(name), float64(n), set)
 		return
 	}
-	attr = fillCommonAttr(attr)
-	gauge.Record(ctx, n, api.WithAttributes(attr...))
+	gauge.Record(ctx, n, api.WithAttributeSet(set))
 }
 
 func getGauge(name string) (api.Int64Gauge, error) {
@@ -113,7 +144,8 @@ func getGauge(name string) (api.Int64Gauge, error) {
 		gauge, ok := gaugeMap.Load(name)
 		if !ok {
 			var err error
-			gauge, err = meter.Int64Gauge(fmt.Sprintf("%v_%v", config.Global.AppName, name))
+			desc, unit := describe(name)
+			gauge, err = meter.Int64Gauge(instrumentName(name), api.WithDescription(desc), api.WithUnit(unit))
 			if err != nil {
 				return nil, err
 			}
@@ -126,3 +158,45 @@ func getGauge(name string) (api.Int64Gauge, error) {
 	}
 	return gauge.(api.Int64Gauge), nil
 }
+
+// EmitUpDown 计量可增可减的值 如连接数 队列长度
+func EmitUpDown(ctx context.Context, name string, incr int64, attr ...attribute.KeyValue) {
+	if !config.Global.UseMetrics {
+		return
+	}
+	upDown, err := getUpDown(name)
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	if validate(ctx, name, KindUpDown, attr) != nil {
+		return
+	}
+	set := limitSeries(ctx, name, fillCommonAttr(redact.Current().Attributes(fillContextAttr(ctx, attr))))
+	observe(name, KindUpDown, float64(incr))
+	if statsd != nil {
+		statsd.upDown(instrumentName(name), incr, set)
+		return
+	}
+	upDown.Add(ctx, incr, api.WithAttributeSet(set))
+}
+
+func getUpDown(name string) (api.Int64UpDownCounter, error) {
+	upDown, err, _ := g.Do(fmt.Sprintf("updown_init_%v", name), func() (interface{}, error) {
+		upDown, ok := upDownMap.Load(name)
+		if !ok {
+			var err error
+			desc, unit := describe(name)
+			upDown, err = meter.Int64UpDownCounter(instrumentName(name), api.WithDescription(desc), api.WithUnit(unit))
+			if err != nil {
+				return nil, err
+			}
+			upDownMap.Store(name, upDown)
+		}
+		return upDown, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return upDown.(api.Int64UpDownCounter), nil
+}
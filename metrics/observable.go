@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/internal/redact"
+	"go.opentelemetry.io/otel/attribute"
+	api "go.opentelemetry.io/otel/metric"
+)
+
+// RegisterGauge 注册异步gauge 每次导出时调用fn取当前值 如队列长度 连接池大小
+func RegisterGauge(name string, fn func() int64, attr ...attribute.KeyValue) error {
+	if !config.Global.UseMetrics {
+		return nil
+	}
+	if err := validate(context.Background(), name, KindObservableGauge, attr); err != nil {
+		return err
+	}
+	desc, unit := describe(name)
+	_, err := meter.Int64ObservableGauge(instrumentName(name), api.WithDescription(desc), api.WithUnit(unit),
+		api.WithInt64Callback(func(ctx context.Context, observer api.Int64Observer) error {
+			observer.Observe(fn(), api.WithAttributeSet(observableAttr(ctx, name, attr)))
+			return nil
+		}))
+	return err
+}
+
+// RegisterCounter 注册异步counter fn返回单调递增的累计值
+func RegisterCounter(name string, fn func() int64, attr ...attribute.KeyValue) error {
+	if !config.Global.UseMetrics {
+		return nil
+	}
+	if err := validate(context.Background(), name, KindObservableCounter, attr); err != nil {
+		return err
+	}
+	desc, unit := describe(name)
+	_, err := meter.Int64ObservableCounter(instrumentName(name), api.WithDescription(desc), api.WithUnit(unit),
+		api.WithInt64Callback(func(ctx context.Context, observer api.Int64Observer) error {
+			observer.Observe(fn(), api.WithAttributeSet(observableAttr(ctx, name, attr)))
+			return nil
+		}))
+	return err
+}
+
+// RegisterUpDown 注册异步updown counter fn返回可增可减的累计值
+func RegisterUpDown(name string, fn func() int64, attr ...attribute.KeyValue) error {
+	if !config.Global.UseMetrics {
+		return nil
+	}
+	if err := validate(context.Background(), name, KindObservableUpDown, attr); err != nil {
+		return err
+	}
+	desc, unit := describe(name)
+	_, err := meter.Int64ObservableUpDownCounter(instrumentName(name), api.WithDescription(desc), api.WithUnit(unit),
+		api.WithInt64Callback(func(ctx context.Context, observer api.Int64Observer) error {
+			observer.Observe(fn(), api.WithAttributeSet(observableAttr(ctx, name, attr)))
+			return nil
+		}))
+	return err
+}
+
+// 和Emit*一样经过公共属性 脱敏和序列数限制 每次回调时处理 脱敏规则修改后同样生效
+func observableAttr(ctx context.Context, name string, attr []attribute.KeyValue) attribute.Set {
+	return limitSeries(ctx, name, fillCommonAttr(redact.Current().Attributes(fillContextAttr(ctx, attr))))
+}
@@ -0,0 +1,33 @@
+package metrics_test
+
+import (
+	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/metrics"
+	"github.com/watora/telemetry/telemetrytest"
+	"go.opentelemetry.io/otel/attribute"
+	"testing"
+)
+
+// 异步指标的属性和Emit*一样带公共属性 经过脱敏
+func TestObservableAttributes(t *testing.T) {
+	telemetrytest.Init(func(cfg *config.Config) {
+		cfg.AppName = "observable"
+		cfg.Env = "test"
+		cfg.RedactRules = []config.RedactRule{{Key: "token"}}
+	})
+	if err := metrics.RegisterGauge("queue_depth", func() int64 { return 3 }, attribute.String("queue", "a"), attribute.String("token", "t1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := metrics.RegisterCounter("queue_total", func() int64 { return 5 }, attribute.String("queue", "a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := metrics.RegisterUpDown("queue_workers", func() int64 { return 2 }, attribute.String("queue", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	telemetrytest.AssertGauge(t, "queue_depth", []attribute.KeyValue{
+		attribute.String("queue", "a"), attribute.String("token", "***"), attribute.String("env", "test"),
+	}, 3)
+	telemetrytest.AssertCounter(t, "queue_total", []attribute.KeyValue{attribute.String("queue", "a"), attribute.String("env", "test")}, 5)
+	telemetrytest.AssertCounter(t, "queue_workers", []attribute.KeyValue{attribute.String("queue", "a"), attribute.String("service.name", "observable")}, 2)
+}
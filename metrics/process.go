@@ -0,0 +1,271 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	api "go.opentelemetry.io/otel/metric"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	procSelf      = "/proc/self"
+	cgroupRoot    = "/sys/fs/cgroup"
+	clockTicks    = 100 // USER_HZ 在linux上固定为100
+	cgroupNoLimit = int64(1) << 62
+)
+
+// 进程与容器指标采集 读取/proc和cgroup 每次导出时读取一次
+type processCollector struct {
+	cgroupV2 bool
+	pageSize int64
+}
+
+// 启动进程与容器指标采集
+func startProcessMetrics() error {
+	_, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	c := &processCollector{
+		cgroupV2: err == nil,
+		pageSize: int64(os.Getpagesize()),
+	}
+
+	cpuTime, err := meter.Float64ObservableCounter("process.cpu.time",
+		api.WithDescription("Total CPU seconds broken down by different states."), api.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+	rss, err := meter.Int64ObservableUpDownCounter("process.memory.usage",
+		api.WithDescription("The amount of physical memory in use."), api.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+	fds, err := meter.Int64ObservableUpDownCounter("process.open_file_descriptor.count",
+		api.WithDescription("Number of file descriptors in use by the process."), api.WithUnit("{file_descriptor}"))
+	if err != nil {
+		return err
+	}
+	threads, err := meter.Int64ObservableUpDownCounter("process.thread.count",
+		api.WithDescription("Process threads count."), api.WithUnit("{thread}"))
+	if err != nil {
+		return err
+	}
+	diskIO, err := meter.Int64ObservableCounter("process.disk.io",
+		api.WithDescription("Disk bytes transferred."), api.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+	netIO, err := meter.Int64ObservableCounter("process.network.io",
+		api.WithDescription("Network bytes transferred."), api.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+	cpuLimit, err := meter.Float64ObservableGauge("container.cpu.limit",
+		api.WithDescription("CPU cores available to the cgroup, absent when unlimited."), api.WithUnit("{cpu}"))
+	if err != nil {
+		return err
+	}
+	throttledPeriods, err := meter.Int64ObservableCounter("container.cpu.throttled.periods",
+		api.WithDescription("Number of periods the cgroup was throttled."), api.WithUnit("{period}"))
+	if err != nil {
+		return err
+	}
+	throttledTime, err := meter.Float64ObservableCounter("container.cpu.throttled.time",
+		api.WithDescription("Total time the cgroup was throttled."), api.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+	memLimit, err := meter.Int64ObservableGauge("container.memory.limit",
+		api.WithDescription("Memory limit of the cgroup, absent when unlimited."), api.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+	memUsage, err := meter.Int64ObservableUpDownCounter("container.memory.usage",
+		api.WithDescription("Memory usage of the cgroup."), api.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+
+	common := fillCommonAttr(nil)
+	withAttr := func(kv ...attribute.KeyValue) api.MeasurementOption {
+		return api.WithAttributes(append(kv, common...)...)
+	}
+	cpuUser, cpuSystem := withAttr(attribute.String("cpu.mode", "user")), withAttr(attribute.String("cpu.mode", "system"))
+	diskRead, diskWrite := withAttr(attribute.String("disk.io.direction", "read")), withAttr(attribute.String("disk.io.direction", "write"))
+	netRecv, netTrans := withAttr(attribute.String("network.io.direction", "receive")), withAttr(attribute.String("network.io.direction", "transmit"))
+	plain := api.WithAttributes(common...)
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, observer api.Observer) error {
+		if stat, ok := readProcStat(); ok {
+			observer.ObserveFloat64(cpuTime, float64(stat.utime)/clockTicks, cpuUser)
+			observer.ObserveFloat64(cpuTime, float64(stat.stime)/clockTicks, cpuSystem)
+			observer.ObserveInt64(threads, stat.threads, plain)
+			observer.ObserveInt64(rss, stat.rss*c.pageSize, plain)
+		}
+		if entries, err := os.ReadDir(filepath.Join(procSelf, "fd")); err == nil {
+			observer.ObserveInt64(fds, int64(len(entries)), plain)
+		}
+		if io := readKeyValues(filepath.Join(procSelf, "io"), ":"); io != nil {
+			observer.ObserveInt64(diskIO, io["read_bytes"], diskRead)
+			observer.ObserveInt64(diskIO, io["write_bytes"], diskWrite)
+		}
+		if rx, tx, ok := readNetDev(); ok {
+			observer.ObserveInt64(netIO, rx, netRecv)
+			observer.ObserveInt64(netIO, tx, netTrans)
+		}
+		cg := c.readCgroup()
+		if cg.cpuLimit > 0 {
+			observer.ObserveFloat64(cpuLimit, cg.cpuLimit, plain)
+		}
+		if cg.hasCPUStat {
+			observer.ObserveInt64(throttledPeriods, cg.throttledPeriods, plain)
+			observer.ObserveFloat64(throttledTime, cg.throttledSeconds, plain)
+		}
+		if cg.memLimit > 0 {
+			observer.ObserveInt64(memLimit, cg.memLimit, plain)
+		}
+		if cg.memUsage > 0 {
+			observer.ObserveInt64(memUsage, cg.memUsage, plain)
+		}
+		return nil
+	}, cpuTime, rss, fds, threads, diskIO, netIO, cpuLimit, throttledPeriods, throttledTime, memLimit, memUsage)
+	return err
+}
+
+type procStat struct {
+	utime   int64
+	stime   int64
+	threads int64
+	rss     int64
+}
+
+// 解析/proc/self/stat 字段含义见man proc
+func readProcStat() (procStat, bool) {
+	data, err := os.ReadFile(filepath.Join(procSelf, "stat"))
+	if err != nil {
+		return procStat{}, false
+	}
+	// comm字段可能包含空格 从最后一个')'之后开始解析 此时fields[0]是第3个字段state
+	s := string(data)
+	fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+	if len(fields) < 22 {
+		return procStat{}, false
+	}
+	field := func(n int) int64 {
+		v, _ := strconv.ParseInt(fields[n-3], 10, 64)
+		return v
+	}
+	return procStat{
+		utime:   field(14),
+		stime:   field(15),
+		threads: field(20),
+		rss:     field(24),
+	}, true
+}
+
+// 汇总非lo网卡的收发字节 容器内即为pod的网络
+func readNetDev() (rx int64, tx int64, ok bool) {
+	f, err := os.Open(filepath.Join(procSelf, "net", "dev"))
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		iface, rest, found := strings.Cut(scanner.Text(), ":")
+		if !found || strings.TrimSpace(iface) == "lo" {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 9 {
+			continue
+		}
+		r, _ := strconv.ParseInt(fields[0], 10, 64)
+		t, _ := strconv.ParseInt(fields[8], 10, 64)
+		rx += r
+		tx += t
+	}
+	return rx, tx, true
+}
+
+type cgroupStat struct {
+	cpuLimit         float64
+	hasCPUStat       bool
+	throttledPeriods int64
+	throttledSeconds float64
+	memLimit         int64
+	memUsage         int64
+}
+
+func (c *processCollector) readCgroup() cgroupStat {
+	var cg cgroupStat
+	if c.cgroupV2 {
+		// cpu.max 格式为 "$MAX $PERIOD" 不限制时MAX为max
+		if fields := strings.Fields(readString(filepath.Join(cgroupRoot, "cpu.max"))); len(fields) == 2 && fields[0] != "max" {
+			quota, _ := strconv.ParseFloat(fields[0], 64)
+			period, _ := strconv.ParseFloat(fields[1], 64)
+			if period > 0 {
+				cg.cpuLimit = quota / period
+			}
+		}
+		if stat := readKeyValues(filepath.Join(cgroupRoot, "cpu.stat"), " "); stat != nil {
+			cg.hasCPUStat = true
+			cg.throttledPeriods = stat["nr_throttled"]
+			cg.throttledSeconds = float64(stat["throttled_usec"]) / 1e6
+		}
+		cg.memLimit = readInt(filepath.Join(cgroupRoot, "memory.max"))
+		cg.memUsage = readInt(filepath.Join(cgroupRoot, "memory.current"))
+	} else {
+		quota := readInt(filepath.Join(cgroupRoot, "cpu", "cpu.cfs_quota_us"))
+		period := readInt(filepath.Join(cgroupRoot, "cpu", "cpu.cfs_period_us"))
+		if quota > 0 && period > 0 {
+			cg.cpuLimit = float64(quota) / float64(period)
+		}
+		if stat := readKeyValues(filepath.Join(cgroupRoot, "cpu", "cpu.stat"), " "); stat != nil {
+			cg.hasCPUStat = true
+			cg.throttledPeriods = stat["nr_throttled"]
+			cg.throttledSeconds = float64(stat["throttled_time"]) / 1e9
+		}
+		cg.memLimit = readInt(filepath.Join(cgroupRoot, "memory", "memory.limit_in_bytes"))
+		cg.memUsage = readInt(filepath.Join(cgroupRoot, "memory", "memory.usage_in_bytes"))
+	}
+	// v1不限制内存时是一个接近int64上限的值
+	if cg.memLimit >= cgroupNoLimit {
+		cg.memLimit = 0
+	}
+	return cg
+}
+
+func readString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// 读取单个整数 读取失败或为max时返回0
+func readInt(path string) int64 {
+	v, _ := strconv.ParseInt(readString(path), 10, 64)
+	return v
+}
+
+// 读取"key<sep>value"格式的文件
+func readKeyValues(path string, sep string) map[string]int64 {
+	data := readString(path)
+	if data == "" {
+		return nil
+	}
+	res := make(map[string]int64)
+	for _, line := range strings.Split(data, "\n") {
+		key, value, found := strings.Cut(line, sep)
+		if !found {
+			continue
+		}
+		v, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		res[strings.TrimSpace(key)] = v
+	}
+	return res
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	api "go.opentelemetry.io/otel/metric"
+	"math"
+	rtmetrics "runtime/metrics"
+	"strings"
+	"sync"
+)
+
+const (
+	rtGoroutines = "/sched/goroutines:goroutines"
+	rtGoMaxProcs = "/sched/gomaxprocs:threads"
+	rtGCCycles   = "/gc/cycles/total:gc-cycles"
+	rtGCPauses   = "/sched/pauses/total/gc:seconds"
+	rtMemPrefix  = "/memory/classes/"
+)
+
+// gc暂停的桶 单位us
+var gcPauseBoundaries = []float64{0, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 25000, 50000, 100000}
+
+// runtime指标采集 基于runtime/metrics 每次导出时读取一次
+type runtimeCollector struct {
+	mu         sync.Mutex
+	samples    []rtmetrics.Sample
+	index      map[string]int
+	memClasses map[string]attribute.Set
+	lastPauses []uint64
+	gcPause    api.Int64Histogram
+}
+
+// 启动runtime指标采集
+func startRuntimeMetrics() error {
+	c := &runtimeCollector{
+		index:      make(map[string]int),
+		memClasses: make(map[string]attribute.Set),
+	}
+	for _, desc := range rtmetrics.All() {
+		name := desc.Name
+		switch {
+		case name == rtGoroutines, name == rtGoMaxProcs, name == rtGCCycles, name == rtGCPauses:
+		case strings.HasPrefix(name, rtMemPrefix) && desc.Kind == rtmetrics.KindUint64 &&
+			name != rtMemPrefix+"total:bytes":
+			class := strings.TrimSuffix(strings.TrimPrefix(name, rtMemPrefix), ":bytes")
+			c.memClasses[name] = attribute.NewSet(fillCommonAttr([]attribute.KeyValue{attribute.String("class", class)})...)
+		default:
+			continue
+		}
+		c.index[name] = len(c.samples)
+		c.samples = append(c.samples, rtmetrics.Sample{Name: name})
+	}
+
+	goroutines, err := meter.Int64ObservableUpDownCounter("go.goroutines",
+		api.WithDescription("Count of live goroutines."), api.WithUnit("{goroutine}"))
+	if err != nil {
+		return err
+	}
+	maxProcs, err := meter.Int64ObservableGauge("go.gomaxprocs",
+		api.WithDescription("The number of OS threads that can execute user-level Go code simultaneously."), api.WithUnit("{thread}"))
+	if err != nil {
+		return err
+	}
+	memory, err := meter.Int64ObservableUpDownCounter("go.memory.classes",
+		api.WithDescription("Memory mapped by the Go runtime, by class."), api.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+	gcCount, err := meter.Int64ObservableCounter("go.gc.count",
+		api.WithDescription("Count of completed GC cycles."), api.WithUnit("{gc_cycle}"))
+	if err != nil {
+		return err
+	}
+	c.gcPause, err = meter.Int64Histogram("go.gc.pause",
+		api.WithDescription("Distribution of individual GC-related stop-the-world pause latencies."), api.WithUnit("us"),
+		api.WithExplicitBucketBoundaries(gcPauseBoundaries...))
+	if err != nil {
+		return err
+	}
+
+	common := api.WithAttributes(fillCommonAttr(nil)...)
+	_, err = meter.RegisterCallback(func(ctx context.Context, observer api.Observer) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		rtmetrics.Read(c.samples)
+		observer.ObserveInt64(goroutines, c.uint64(rtGoroutines), common)
+		observer.ObserveInt64(maxProcs, c.uint64(rtGoMaxProcs), common)
+		observer.ObserveInt64(gcCount, c.uint64(rtGCCycles), common)
+		for name, set := range c.memClasses {
+			observer.ObserveInt64(memory, c.uint64(name), api.WithAttributeSet(set))
+		}
+		c.recordPauses(ctx)
+		return nil
+	}, goroutines, maxProcs, memory, gcCount)
+	return err
+}
+
+func (c *runtimeCollector) uint64(name string) int64 {
+	i, ok := c.index[name]
+	if !ok || c.samples[i].Value.Kind() != rtmetrics.KindUint64 {
+		return 0
+	}
+	return int64(c.samples[i].Value.Uint64())
+}
+
+// runtime给的是累计分布 按桶计算增量后记录到直方图
+func (c *runtimeCollector) recordPauses(ctx context.Context) {
+	i, ok := c.index[rtGCPauses]
+	if !ok || c.samples[i].Value.Kind() != rtmetrics.KindFloat64Histogram {
+		return
+	}
+	hist := c.samples[i].Value.Float64Histogram()
+	if c.lastPauses == nil {
+		// 首次只记录基线 避免把启动前的暂停一次性导出
+		c.lastPauses = append([]uint64(nil), hist.Counts...)
+		return
+	}
+	attr := api.WithAttributes(fillCommonAttr(nil)...)
+	for n, count := range hist.Counts {
+		delta := count - c.lastPauses[n]
+		if delta == 0 {
+			continue
+		}
+		// 取桶上界 上界为Inf时取下界
+		bound := hist.Buckets[n+1]
+		if math.IsInf(bound, 1) {
+			bound = hist.Buckets[n]
+		}
+		us := int64(bound * 1e6)
+		for ; delta > 0; delta-- {
+			c.gcPause.Record(ctx, us, attr)
+		}
+	}
+	copy(c.lastPauses, hist.Counts)
+}
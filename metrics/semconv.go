@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/internal/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	api "go.opentelemetry.io/otel/metric"
+	"time"
+)
+
+// 语义约定耗时直方图的桶 单位s
+var semconvBoundaries = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// EmitDuration 按otel语义约定记录耗时 如http.server.request.duration
+// 名称不加AppName前缀 单位为秒 不填充公共属性 服务标识在resource中
+func EmitDuration(ctx context.Context, name string, d time.Duration, attr ...attribute.KeyValue) {
+	if !config.Global.UseMetrics {
+		return
+	}
+	duration, err := getDuration(name)
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	if validate(ctx, name, KindHistogram, attr) != nil {
+		return
+	}
+	set := limitSeries(ctx, name, redact.Current().Attributes(fillContextAttr(ctx, attr)))
+	observe(name, KindHistogram, d.Seconds())
+	if statsd != nil {
+		statsd.timing(name, float64(d)/float64(time.Millisecond), set)
+		return
+	}
+	duration.Record(ctx, d.Seconds(), api.WithAttributeSet(set))
+}
+
+func getDuration(name string) (api.Float64Histogram, error) {
+	duration, err, _ := g.Do(fmt.Sprintf("duration_init_%v", name), func() (interface{}, error) {
+		duration, ok := durationMap.Load(name)
+		if !ok {
+			var err error
+			desc, _ := describe(name)
+			duration, err = meter.Float64Histogram(name, api.WithDescription(desc), api.WithUnit("s"),
+				api.WithExplicitBucketBoundaries(semconvBoundaries...))
+			if err != nil {
+				return nil, err
+			}
+			durationMap.Store(name, duration)
+		}
+		return duration, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return duration.(api.Float64Histogram), nil
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"github.com/watora/telemetry/internal/health"
+	"github.com/watora/telemetry/internal/spool"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+var metricSpool *spool.Spool
+
+// 导出失败时把请求落盘 恢复后先按顺序重放
+func spoolInterceptor(s *spool.Spool) grpc.UnaryClientInterceptor {
+	return spool.Interceptor(s, health.Metrics, requestDataPoints)
+}
+
+// 请求中的数据点数 和healthExporter的计数一致
+func requestDataPoints(msg proto.Message) int {
+	req, ok := msg.(*colmetricpb.ExportMetricsServiceRequest)
+	if !ok {
+		return 0
+	}
+	n := 0
+	for _, rm := range req.GetResourceMetrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				switch data := m.GetData().(type) {
+				case *metricpb.Metric_Gauge:
+					n += len(data.Gauge.GetDataPoints())
+				case *metricpb.Metric_Sum:
+					n += len(data.Sum.GetDataPoints())
+				case *metricpb.Metric_Histogram:
+					n += len(data.Histogram.GetDataPoints())
+				case *metricpb.Metric_ExponentialHistogram:
+					n += len(data.ExponentialHistogram.GetDataPoints())
+				case *metricpb.Metric_Summary:
+					n += len(data.Summary.GetDataPoints())
+				}
+			}
+		}
+	}
+	return n
+}
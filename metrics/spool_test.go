@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"context"
+	"github.com/watora/telemetry/internal/health"
+	"github.com/watora/telemetry/internal/spool"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// n个数据点的请求 指标名用于区分
+func metricsRequest(name string, n int) *colmetricpb.ExportMetricsServiceRequest {
+	points := make([]*metricpb.NumberDataPoint, n)
+	for i := range points {
+		points[i] = &metricpb.NumberDataPoint{Value: &metricpb.NumberDataPoint_AsInt{AsInt: int64(i)}}
+	}
+	return &colmetricpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricpb.ResourceMetrics{{ScopeMetrics: []*metricpb.ScopeMetrics{{Metrics: []*metricpb.Metric{{
+			Name: name,
+			Data: &metricpb.Metric_Sum{Sum: &metricpb.Sum{DataPoints: points}},
+		}}}}}},
+	}
+}
+
+func TestSpoolInterceptorHealth(t *testing.T) {
+	s, err := spool.New(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fail error
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return fail
+	}
+	intercept := spoolInterceptor(s)
+	export := func(req *colmetricpb.ExportMetricsServiceRequest) error {
+		return intercept(context.Background(), "/Export", req, &colmetricpb.ExportMetricsServiceResponse{}, nil, invoker)
+	}
+	before := health.Snapshot()[health.Metrics]
+
+	// 收集器不可用 落盘后返回成功 但不算导出成功
+	fail = status.Error(codes.Unavailable, "down")
+	if err := export(metricsRequest("down", 4)); err != nil {
+		t.Fatal(err)
+	}
+	current := health.Snapshot()[health.Metrics]
+	if current.Exported != before.Exported || !current.LastSuccess.Equal(before.LastSuccess) {
+		t.Errorf("spooled export counted as success: %+v", current)
+	}
+	if got := current.Spooled - before.Spooled; got != 4 {
+		t.Errorf("spooled = %v, want 4", got)
+	}
+
+	// 恢复后重放的和当前的数据都算成功
+	fail = nil
+	if err := export(metricsRequest("up", 2)); err != nil {
+		t.Fatal(err)
+	}
+	current = health.Snapshot()[health.Metrics]
+	if got := current.Exported - before.Exported; got != 6 {
+		t.Errorf("exported = %v, want 6", got)
+	}
+	if s.Len() != 0 {
+		t.Errorf("spool len = %v, want 0", s.Len())
+	}
+}
+
+// 记录收到的指标名 code不为OK时返回错误
+type stubCollector struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+	mu    sync.Mutex
+	code  codes.Code
+	names []string
+}
+
+func (c *stubCollector) Export(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.code != codes.OK {
+		return nil, status.Error(c.code, c.code.String())
+	}
+	c.names = append(c.names, req.GetResourceMetrics()[0].GetScopeMetrics()[0].GetMetrics()[0].GetName())
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+func (c *stubCollector) setCode(code codes.Code) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.code = code
+}
+
+func (c *stubCollector) received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.names...)
+}
+
+// 连接到进程内收集器的client 请求经过落盘拦截器
+func newStubClient(t *testing.T, s *spool.Spool) (colmetricpb.MetricsServiceClient, *stubCollector) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := &stubCollector{}
+	srv := grpc.NewServer()
+	colmetricpb.RegisterMetricsServiceServer(srv, collector)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(spoolInterceptor(s)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return colmetricpb.NewMetricsServiceClient(conn), collector
+}
+
+func TestSpoolInterceptorReplay(t *testing.T) {
+	dir := t.TempDir()
+	s, err := spool.New(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, collector := newStubClient(t, s)
+	ctx := context.Background()
+	collector.setCode(codes.Unavailable)
+	for _, name := range []string{"a", "b"} {
+		if _, err := client.Export(ctx, metricsRequest(name, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 重启后重新打开 落盘的请求在当前数据之前重放
+	s, err = spool.New(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, collector = newStubClient(t, s)
+	if _, err := client.Export(ctx, metricsRequest("c", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := collector.received(); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("collector received %v", got)
+	}
+	if s.Len() != 0 {
+		t.Errorf("spool len = %v, want 0", s.Len())
+	}
+}
+
+func TestSpoolInterceptorDropsNonRetryable(t *testing.T) {
+	s, err := spool.New(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, collector := newStubClient(t, s)
+	ctx := context.Background()
+	collector.setCode(codes.Unavailable)
+	if _, err := client.Export(ctx, metricsRequest("a", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重放时收集器拒绝的请求丢掉 当前请求的错误返回给exporter
+	collector.setCode(codes.InvalidArgument)
+	if _, err := client.Export(ctx, metricsRequest("b", 1)); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("err = %v, want InvalidArgument", err)
+	}
+	if s.Len() != 0 || s.Dropped() != 1 {
+		t.Errorf("spool len = %v dropped = %v, want 0 1", s.Len(), s.Dropped())
+	}
+
+	collector.setCode(codes.OK)
+	if _, err := client.Export(ctx, metricsRequest("c", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := collector.received(); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("collector received %v", got)
+	}
+}
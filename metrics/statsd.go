@@ -0,0 +1,252 @@
+package metrics
+
+import (
+	"context"
+	"github.com/watora/telemetry/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 以太网MTU减去IP和UDP头
+const defaultStatsdMTU = 1432
+
+// 不为nil时同步指标直接发往statsd
+var statsd *statsdClient
+
+// statsd客户端 把多行合并成不超过MTU的udp包
+type statsdClient struct {
+	conn net.Conn
+	mtu  int
+	dog  bool // dogstatsd格式 支持tag
+
+	mu     sync.Mutex
+	buf    []byte
+	closed bool
+	// updown按序列累加 以gauge发送当前值
+	totals map[upDownKey]int64
+
+	ticker    *time.Ticker
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type upDownKey struct {
+	name string
+	set  attribute.Distinct
+}
+
+func newStatsdClient(addr string, mtu int, dog bool) (*statsdClient, error) {
+	if addr == "" {
+		addr = "127.0.0.1:8125"
+	}
+	if mtu <= 0 {
+		mtu = defaultStatsdMTU
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &statsdClient{
+		conn:   conn,
+		mtu:    mtu,
+		dog:    dog,
+		buf:    make([]byte, 0, mtu),
+		totals: map[upDownKey]int64{},
+		ticker: time.NewTicker(time.Second),
+		done:   make(chan struct{}),
+	}
+	// 流量小时定时发送 避免数据一直留在缓冲区
+	go func() {
+		for {
+			select {
+			case <-c.ticker.C:
+				c.flush()
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return c, nil
+}
+
+// 停止定时发送 发出缓冲区的数据后关闭连接
+func (c *statsdClient) close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.ticker.Stop()
+		close(c.done)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.flushLocked()
+		c.closed = true
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// 发送一行 格式为 name:value|type|#k:v,k:v
+func (c *statsdClient) send(name string, value string, typ string, set attribute.Set) {
+	line := make([]byte, 0, 128)
+	line = append(line, sanitizeName(name)...)
+	line = append(line, ':')
+	line = append(line, value...)
+	line = append(line, '|')
+	line = append(line, typ...)
+	if c.dog && set.Len() > 0 {
+		line = append(line, "|#"...)
+		for i, kv := range set.ToSlice() {
+			if i > 0 {
+				line = append(line, ',')
+			}
+			line = append(line, sanitizeTag(string(kv.Key))...)
+			line = append(line, ':')
+			line = append(line, sanitizeTag(kv.Value.Emit())...)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.buf) > 0 && len(c.buf)+1+len(line) > c.mtu {
+		c.flushLocked()
+	}
+	if len(c.buf) > 0 {
+		c.buf = append(c.buf, '\n')
+	}
+	c.buf = append(c.buf, line...)
+	// 单行超过MTU时单独发送
+	if len(c.buf) >= c.mtu {
+		c.flushLocked()
+	}
+}
+
+func (c *statsdClient) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+}
+
+func (c *statsdClient) flushLocked() {
+	if len(c.buf) == 0 || c.closed {
+		c.buf = c.buf[:0]
+		return
+	}
+	// udp发送失败没有重试的意义 直接丢弃
+	_, _ = c.conn.Write(c.buf)
+	c.buf = c.buf[:0]
+}
+
+var nameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "\n", "_", " ", "_")
+var tagReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+func sanitizeName(name string) string {
+	return nameReplacer.Replace(name)
+}
+
+func sanitizeTag(tag string) string {
+	return tagReplacer.Replace(tag)
+}
+
+func (c *statsdClient) count(name string, n int64, set attribute.Set) {
+	c.send(name, strconv.FormatInt(n, 10), "c", set)
+}
+
+func (c *statsdClient) gauge(name string, n float64, set attribute.Set) {
+	c.send(name, strconv.FormatFloat(n, 'f', -1, 64), "g", set)
+}
+
+func (c *statsdClient) timing(name string, ms float64, set attribute.Set) {
+	c.send(name, strconv.FormatFloat(ms, 'f', -1, 64), "ms", set)
+}
+
+// 增减值 按序列累加后以gauge发送当前值 如连接数 agent重启或丢包后下一次发送即可恢复
+func (c *statsdClient) upDown(name string, n int64, set attribute.Set) {
+	key := upDownKey{name: name, set: set.Equivalent()}
+	c.mu.Lock()
+	c.totals[key] += n
+	total := c.totals[key]
+	c.mu.Unlock()
+	c.gauge(name, float64(total), set)
+}
+
+// 异步指标和runtime指标通过reader导出到statsd 同步指标在Emit时直接发送
+type statsdExporter struct {
+	client *statsdClient
+}
+
+func (e *statsdExporter) Temporality(kind metric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case metric.InstrumentKindUpDownCounter, metric.InstrumentKindObservableUpDownCounter:
+		return metricdata.CumulativeTemporality
+	}
+	return metricdata.DeltaTemporality
+}
+
+func (e *statsdExporter) Aggregation(kind metric.InstrumentKind) metric.Aggregation {
+	return metric.DefaultAggregationSelector(kind)
+}
+
+func (e *statsdExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					if data.IsMonotonic {
+						e.client.count(m.Name, dp.Value, dp.Attributes)
+					} else {
+						e.client.gauge(m.Name, float64(dp.Value), dp.Attributes)
+					}
+				}
+			case metricdata.Sum[float64]:
+				for _, dp := range data.DataPoints {
+					if data.IsMonotonic {
+						e.client.send(m.Name, strconv.FormatFloat(dp.Value, 'f', -1, 64), "c", dp.Attributes)
+					} else {
+						e.client.gauge(m.Name, dp.Value, dp.Attributes)
+					}
+				}
+			case metricdata.Gauge[int64]:
+				for _, dp := range data.DataPoints {
+					e.client.gauge(m.Name, float64(dp.Value), dp.Attributes)
+				}
+			case metricdata.Gauge[float64]:
+				for _, dp := range data.DataPoints {
+					e.client.gauge(m.Name, dp.Value, dp.Attributes)
+				}
+			case metricdata.Histogram[int64]:
+				// 聚合后拿不到原始值 只导出次数和总和
+				for _, dp := range data.DataPoints {
+					e.client.count(m.Name+".count", int64(dp.Count), dp.Attributes)
+					e.client.count(m.Name+".sum", dp.Sum, dp.Attributes)
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					e.client.count(m.Name+".count", int64(dp.Count), dp.Attributes)
+					e.client.send(m.Name+".sum", strconv.FormatFloat(dp.Sum, 'f', -1, 64), "c", dp.Attributes)
+				}
+			}
+		}
+	}
+	e.client.flush()
+	return ctx.Err()
+}
+
+func (e *statsdExporter) ForceFlush(ctx context.Context) error {
+	e.client.flush()
+	return ctx.Err()
+}
+
+func (e *statsdExporter) Shutdown(ctx context.Context) error {
+	return e.client.close()
+}
+
+// 是否导出到statsd
+func useStatsd() bool {
+	return config.Global.MetricsExporter == "statsd" || config.Global.MetricsExporter == "dogstatsd"
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listenStatsd(t *testing.T) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// 读取收到的udp包 超时后返回
+func readPackets(t *testing.T, conn net.PacketConn, timeout time.Duration) []string {
+	t.Helper()
+	var packets []string
+	buf := make([]byte, 65536)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(timeout))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return packets
+		}
+		packets = append(packets, string(buf[:n]))
+	}
+}
+
+func TestStatsdLineFormat(t *testing.T) {
+	tags := attribute.NewSet(attribute.String("path", "/a|b"), attribute.Int("code", 200))
+	tests := []struct {
+		name string
+		dog  bool
+		emit func(c *statsdClient)
+		want string
+	}{
+		{"count", false, func(c *statsdClient) { c.count("app_http:count", 3, tags) }, "app_http_count:3|c"},
+		{"gauge", false, func(c *statsdClient) { c.gauge("app_queue", 1.5, tags) }, "app_queue:1.5|g"},
+		{"timing", false, func(c *statsdClient) { c.timing("app_latency", 12, tags) }, "app_latency:12|ms"},
+		{"dogstatsd tags", true, func(c *statsdClient) { c.count("app_http", 1, tags) }, "app_http:1|c|#code:200,path:/a_b"},
+		{"no tags", true, func(c *statsdClient) { c.count("app_http", 1, attribute.NewSet()) }, "app_http:1|c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := listenStatsd(t)
+			c, err := newStatsdClient(conn.LocalAddr().String(), 0, tt.dog)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.close()
+			tt.emit(c)
+			c.flush()
+			packets := readPackets(t, conn, 200*time.Millisecond)
+			if len(packets) != 1 || packets[0] != tt.want {
+				t.Errorf("packets = %q, want %q", packets, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsdMTUSplit(t *testing.T) {
+	conn := listenStatsd(t)
+	mtu := 64
+	c, err := newStatsdClient(conn.LocalAddr().String(), mtu, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+	for i := 0; i < 20; i++ {
+		c.count("app_requests_total", int64(i), attribute.NewSet())
+	}
+	c.flush()
+	packets := readPackets(t, conn, 200*time.Millisecond)
+	if len(packets) < 2 {
+		t.Fatalf("packets = %v, want more than one", len(packets))
+	}
+	lines := 0
+	for _, p := range packets {
+		if len(p) > mtu {
+			t.Errorf("packet of %v bytes exceeds mtu %v: %q", len(p), mtu, p)
+		}
+		for _, line := range strings.Split(p, "\n") {
+			if !strings.HasPrefix(line, "app_requests_total:") || !strings.HasSuffix(line, "|c") {
+				t.Errorf("broken line %q", line)
+			}
+			lines++
+		}
+	}
+	if lines != 20 {
+		t.Errorf("lines = %v, want 20", lines)
+	}
+}
+
+func TestStatsdUpDownSendsLevel(t *testing.T) {
+	for _, dog := range []bool{false, true} {
+		conn := listenStatsd(t)
+		c, err := newStatsdClient(conn.LocalAddr().String(), 0, dog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set := attribute.NewSet(attribute.String("server", "a"))
+		c.upDown("app_live", 1, set)
+		c.upDown("app_live", 1, set)
+		c.upDown("app_live", -1, set)
+		c.upDown("app_live", 1, attribute.NewSet(attribute.String("server", "b")))
+		c.flush()
+		packets := readPackets(t, conn, 200*time.Millisecond)
+		_ = c.close()
+		want := "app_live:1|g\napp_live:2|g\napp_live:1|g\napp_live:1|g"
+		if dog {
+			want = "app_live:1|g|#server:a\napp_live:2|g|#server:a\napp_live:1|g|#server:a\napp_live:1|g|#server:b"
+		}
+		if len(packets) != 1 || packets[0] != want {
+			t.Errorf("dog=%v packets = %q, want %q", dog, packets, want)
+		}
+	}
+}
+
+func TestStatsdClose(t *testing.T) {
+	conn := listenStatsd(t)
+	c, err := newStatsdClient(conn.LocalAddr().String(), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.count("app_before", 1, attribute.NewSet())
+	if err := c.close(); err != nil {
+		t.Fatal(err)
+	}
+	// 关闭时发出缓冲区 之后的数据丢弃 不会写已关闭的连接
+	c.count("app_after", 1, attribute.NewSet())
+	c.flush()
+	_ = c.close()
+	packets := readPackets(t, conn, 200*time.Millisecond)
+	if len(packets) != 1 || packets[0] != "app_before:1|c" {
+		t.Errorf("packets = %q", packets)
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Errorf("flush goroutine not stopped")
+	}
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var kindNames = map[metric.InstrumentKind]string{
+	metric.InstrumentKindCounter:                 "counter",
+	metric.InstrumentKindHistogram:               "histogram",
+	metric.InstrumentKindUpDownCounter:           "updown",
+	metric.InstrumentKindGauge:                   "gauge",
+	metric.InstrumentKindObservableCounter:       "observable_counter",
+	metric.InstrumentKindObservableUpDownCounter: "observable_updown",
+	metric.InstrumentKindObservableGauge:         "observable_gauge",
+}
+
+// 按偏好和仪表类型选择时间性 规则同OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE
+func temporalitySelector(preference string, kinds map[string]string) metric.TemporalitySelector {
+	return func(kind metric.InstrumentKind) metricdata.Temporality {
+		switch kinds[kindNames[kind]] {
+		case "delta":
+			return metricdata.DeltaTemporality
+		case "cumulative":
+			return metricdata.CumulativeTemporality
+		}
+		switch preference {
+		case "delta":
+			// updown表示的是当前值 使用delta没有意义
+			switch kind {
+			case metric.InstrumentKindUpDownCounter, metric.InstrumentKindObservableUpDownCounter:
+				return metricdata.CumulativeTemporality
+			}
+			return metricdata.DeltaTemporality
+		case "lowmemory":
+			// 只有同步的counter和直方图使用delta
+			switch kind {
+			case metric.InstrumentKindCounter, metric.InstrumentKindHistogram:
+				return metricdata.DeltaTemporality
+			}
+		}
+		return metricdata.CumulativeTemporality
+	}
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/watora/telemetry/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"strings"
+)
+
+// 默认直方图桶 单位ms
+var defaultBoundaries = []float64{0, 1, 2, 5, 10, 20, 30, 50, 75, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// 导出的仪表名称
+func instrumentName(name string) string {
+	return fmt.Sprintf("%v_%v", config.Global.AppName, name)
+}
+
+// 按配置构建view 按顺序匹配 都没命中的业务直方图使用默认桶
+func buildView(cfgs []config.MetricView) (metric.View, error) {
+	views := make([]metric.View, 0, len(cfgs)+1)
+	for _, cfg := range cfgs {
+		view, err := newConfigView(cfg)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	views = append(views, metric.NewView(
+		metric.Instrument{
+			Name: instrumentName("*"),
+			Kind: metric.InstrumentKindHistogram,
+		},
+		metric.Stream{
+			// 调整桶的精度
+			Aggregation: metric.AggregationExplicitBucketHistogram{
+				Boundaries: defaultBoundaries,
+			},
+		},
+	))
+	return func(i metric.Instrument) (metric.Stream, bool) {
+		for _, view := range views {
+			if stream, ok := view(i); ok {
+				return stream, true
+			}
+		}
+		return metric.Stream{}, false
+	}, nil
+}
+
+func newConfigView(cfg config.MetricView) (metric.View, error) {
+	// sdk会忽略通配的view上的Rename 多个指标也不能使用同一个名称
+	if cfg.Rename != "" && strings.ContainsAny(cfg.Name, "*?") {
+		return nil, fmt.Errorf("metric view %q: rename is not allowed with wildcard name", cfg.Name)
+	}
+	mask := metric.Stream{}
+	if cfg.Rename != "" {
+		mask.Name = instrumentName(cfg.Rename)
+	}
+	if len(cfg.AllowedKeys) > 0 {
+		keys := make([]attribute.Key, 0, len(cfg.AllowedKeys))
+		for _, key := range cfg.AllowedKeys {
+			keys = append(keys, attribute.Key(key))
+		}
+		mask.AttributeFilter = attribute.NewAllowKeysFilter(keys...)
+	}
+	switch {
+	case cfg.Drop:
+		mask.Aggregation = metric.AggregationDrop{}
+	case cfg.Exponential:
+		agg := metric.AggregationBase2ExponentialHistogram{
+			MaxSize:  cfg.MaxSize,
+			MaxScale: cfg.MaxScale,
+		}
+		if agg.MaxSize == 0 {
+			agg.MaxSize = 160
+		}
+		if agg.MaxScale == 0 {
+			agg.MaxScale = 20
+		}
+		mask.Aggregation = agg
+	case len(cfg.Boundaries) > 0:
+		mask.Aggregation = metric.AggregationExplicitBucketHistogram{
+			Boundaries: cfg.Boundaries,
+		}
+	}
+	view := metric.NewView(metric.Instrument{Name: instrumentName(cfg.Name)}, mask)
+	// 不带前缀的指标 如runtime和语义约定的指标
+	rawMask := mask
+	if cfg.Rename != "" {
+		rawMask.Name = cfg.Rename
+	}
+	rawView := metric.NewView(metric.Instrument{Name: cfg.Name}, rawMask)
+	return func(i metric.Instrument) (metric.Stream, bool) {
+		stream, ok := view(i)
+		if !ok {
+			stream, ok = rawView(i)
+			if ok && !cfg.Drop && i.Kind != metric.InstrumentKindHistogram {
+				stream.Aggregation = nil
+			}
+			return stream, ok
+		}
+		if cfg.Drop {
+			return stream, ok
+		}
+		// 直方图聚合只对直方图生效 其他类型保留默认聚合
+		if i.Kind != metric.InstrumentKindHistogram {
+			stream.Aggregation = nil
+		} else if stream.Aggregation == nil {
+			stream.Aggregation = metric.AggregationExplicitBucketHistogram{
+				Boundaries: defaultBoundaries,
+			}
+		}
+		return stream, ok
+	}, nil
+}
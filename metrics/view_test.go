@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"github.com/watora/telemetry/config"
+	"testing"
+)
+
+func TestBuildViewRejectsWildcardRename(t *testing.T) {
+	tests := []struct {
+		name    string
+		view    config.MetricView
+		wantErr bool
+	}{
+		{name: "rename", view: config.MetricView{Name: "zinx_live", Rename: "zinx_connections"}},
+		{name: "wildcard", view: config.MetricView{Name: "http_*", Drop: true}},
+		{name: "wildcard rename", view: config.MetricView{Name: "http_*", Rename: "http"}, wantErr: true},
+		{name: "single char wildcard rename", view: config.MetricView{Name: "http_coun?", Rename: "http"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildView([]config.MetricView{tt.view})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
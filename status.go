@@ -0,0 +1,27 @@
+package telemetry
+
+import (
+	"github.com/watora/telemetry/internal/health"
+	"time"
+)
+
+type SignalStatus = health.SignalStatus
+
+// StatusInfo 导出管道的状态
+type StatusInfo struct {
+	Signals       map[string]SignalStatus `json:"signals"` // key: logs metrics spans
+	Errors        int64                   `json:"errors"`  // otel上报的错误次数
+	LastError     string                  `json:"last_error,omitempty"`
+	LastErrorTime time.Time               `json:"last_error_time"`
+}
+
+// Status 各个信号的导出情况 包括最后一次成功导出的时间
+func Status() StatusInfo {
+	count, last, at := health.Errors()
+	return StatusInfo{
+		Signals:       health.Snapshot(),
+		Errors:        count,
+		LastError:     last,
+		LastErrorTime: at,
+	}
+}
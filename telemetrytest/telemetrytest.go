@@ -0,0 +1,257 @@
+// Package telemetrytest 在单测中把日志 指标 span记录到内存 并提供断言方法
+package telemetrytest
+
+import (
+	"context"
+	"fmt"
+	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/internal/setup"
+	"github.com/watora/telemetry/log"
+	"github.com/watora/telemetry/metrics"
+	"github.com/watora/telemetry/trace"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"sync"
+	"testing"
+)
+
+var reader *metric.ManualReader
+var spans *tracetest.InMemoryExporter
+var logs *logRecorder
+
+// Init 使用内存exporter初始化 每个测试开始时调用一次
+// 会清掉之前记录的数据 上一个测试修改的配置 声明的指标和单独设置的日志级别
+func Init(fn func(cfg *config.Config)) {
+	*config.Global = config.Config{}
+	metrics.ResetCatalog()
+	for name := range log.LoggerLevels() {
+		log.ResetLoggerLevel(name)
+	}
+	setup.Prepare(func(cfg *config.Config) {
+		cfg.UseMetrics = true
+		cfg.UseLogger = true
+		if fn != nil {
+			fn(cfg)
+		}
+	})
+
+	spans = tracetest.NewInMemoryExporter()
+	trace.InitWithProcessor(sdktrace.NewSimpleSpanProcessor(spans))
+	reader = metric.NewManualReader()
+	metrics.InitWithReader(reader)
+	logs = &logRecorder{}
+	log.InitWithProcessor(logs)
+}
+
+// Collect 收集当前的指标
+func Collect(t testing.TB) metricdata.ResourceMetrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect metrics: %v", err)
+	}
+	return rm
+}
+
+// FindMetric 按名称查找指标 名称可以不带AppName前缀
+func FindMetric(t testing.TB, name string) (metricdata.Metrics, bool) {
+	t.Helper()
+	rm := Collect(t)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name || m.Name == fmt.Sprintf("%v_%v", config.Global.AppName, name) {
+				return m, true
+			}
+		}
+	}
+	return metricdata.Metrics{}, false
+}
+
+// CounterValue 累加属性包含attrs的所有数据点
+func CounterValue(t testing.TB, name string, attrs ...attribute.KeyValue) (int64, bool) {
+	t.Helper()
+	m, ok := FindMetric(t, name)
+	if !ok {
+		return 0, false
+	}
+	sum, ok := m.Data.(metricdata.Sum[int64])
+	if !ok {
+		return 0, false
+	}
+	var value int64
+	found := false
+	for _, dp := range sum.DataPoints {
+		if containsAll(dp.Attributes, attrs) {
+			value += dp.Value
+			found = true
+		}
+	}
+	return value, found
+}
+
+// AssertCounter 断言counter在属性包含attrs的数据点上的累加值
+func AssertCounter(t testing.TB, name string, attrs []attribute.KeyValue, value int64) {
+	t.Helper()
+	got, ok := CounterValue(t, name, attrs...)
+	if !ok {
+		t.Errorf("counter %v with attributes %v not found", name, attrs)
+		return
+	}
+	if got != value {
+		t.Errorf("counter %v with attributes %v = %v, want %v", name, attrs, got, value)
+	}
+}
+
+// GaugeValue 属性包含attrs的数据点的当前值
+func GaugeValue(t testing.TB, name string, attrs ...attribute.KeyValue) (int64, bool) {
+	t.Helper()
+	m, ok := FindMetric(t, name)
+	if !ok {
+		return 0, false
+	}
+	gauge, ok := m.Data.(metricdata.Gauge[int64])
+	if !ok {
+		return 0, false
+	}
+	for _, dp := range gauge.DataPoints {
+		if containsAll(dp.Attributes, attrs) {
+			return dp.Value, true
+		}
+	}
+	return 0, false
+}
+
+// AssertGauge 断言gauge在属性包含attrs的数据点上的当前值
+func AssertGauge(t testing.TB, name string, attrs []attribute.KeyValue, value int64) {
+	t.Helper()
+	got, ok := GaugeValue(t, name, attrs...)
+	if !ok {
+		t.Errorf("gauge %v with attributes %v not found", name, attrs)
+		return
+	}
+	if got != value {
+		t.Errorf("gauge %v with attributes %v = %v, want %v", name, attrs, got, value)
+	}
+}
+
+// HistogramCount 累加属性包含attrs的数据点的记录次数
+func HistogramCount(t testing.TB, name string, attrs ...attribute.KeyValue) (uint64, bool) {
+	t.Helper()
+	m, ok := FindMetric(t, name)
+	if !ok {
+		return 0, false
+	}
+	var count uint64
+	found := false
+	switch data := m.Data.(type) {
+	case metricdata.Histogram[int64]:
+		for _, dp := range data.DataPoints {
+			if containsAll(dp.Attributes, attrs) {
+				count += dp.Count
+				found = true
+			}
+		}
+	case metricdata.Histogram[float64]:
+		for _, dp := range data.DataPoints {
+			if containsAll(dp.Attributes, attrs) {
+				count += dp.Count
+				found = true
+			}
+		}
+	case metricdata.ExponentialHistogram[int64]:
+		for _, dp := range data.DataPoints {
+			if containsAll(dp.Attributes, attrs) {
+				count += dp.Count
+				found = true
+			}
+		}
+	}
+	return count, found
+}
+
+// AssertHistogramCount 断言直方图在属性包含attrs的数据点上的记录次数
+func AssertHistogramCount(t testing.TB, name string, attrs []attribute.KeyValue, count uint64) {
+	t.Helper()
+	got, ok := HistogramCount(t, name, attrs...)
+	if !ok {
+		t.Errorf("histogram %v with attributes %v not found", name, attrs)
+		return
+	}
+	if got != count {
+		t.Errorf("histogram %v with attributes %v count = %v, want %v", name, attrs, got, count)
+	}
+}
+
+func containsAll(set attribute.Set, attrs []attribute.KeyValue) bool {
+	for _, kv := range attrs {
+		v, ok := set.Value(kv.Key)
+		if !ok || v != kv.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// Spans 所有已结束的span
+func Spans() tracetest.SpanStubs {
+	return spans.GetSpans()
+}
+
+// FindSpans 按名称查找已结束的span
+func FindSpans(name string) tracetest.SpanStubs {
+	var res tracetest.SpanStubs
+	for _, span := range spans.GetSpans() {
+		if span.Name == name {
+			res = append(res, span)
+		}
+	}
+	return res
+}
+
+// Logs 所有导出到otel的日志
+func Logs() []sdklog.Record {
+	return logs.records()
+}
+
+// LogsWithLevel 按级别查找日志
+func LogsWithLevel(level otellog.Severity) []sdklog.Record {
+	var res []sdklog.Record
+	for _, r := range logs.records() {
+		if r.Severity() == level {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// 记录到内存的log processor
+type logRecorder struct {
+	mu   sync.Mutex
+	logs []sdklog.Record
+}
+
+func (r *logRecorder) OnEmit(ctx context.Context, record *sdklog.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, record.Clone())
+	return nil
+}
+
+func (r *logRecorder) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (r *logRecorder) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+func (r *logRecorder) records() []sdklog.Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]sdklog.Record(nil), r.logs...)
+}
@@ -0,0 +1,79 @@
+package telemetrytest
+
+import (
+	"context"
+	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/log"
+	"github.com/watora/telemetry/metrics"
+	"github.com/watora/telemetry/trace"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.uber.org/zap/zapcore"
+	"testing"
+)
+
+func TestRecordsAllSignals(t *testing.T) {
+	Init(func(cfg *config.Config) {
+		cfg.AppName = "test-app"
+	})
+	if config.Global.AppName != "test_app" {
+		t.Fatalf("AppName = %v, want test_app", config.Global.AppName)
+	}
+	ctx, span := trace.StartTrace(context.Background(), "op")
+	metrics.EmitCount(ctx, "orders", 2, attribute.String("channel", "web"))
+	metrics.EmitCount(ctx, "orders", 1, attribute.String("channel", "app"))
+	metrics.EmitTime(ctx, "latency", 12)
+	log.CtxError(ctx, "failed")
+	span.End()
+
+	AssertCounter(t, "orders", []attribute.KeyValue{attribute.String("channel", "web")}, 2)
+	AssertCounter(t, "orders", nil, 3)
+	AssertHistogramCount(t, "latency", nil, 1)
+	if got := len(FindSpans("op")); got != 1 {
+		t.Errorf("spans named op = %v, want 1", got)
+	}
+	errs := LogsWithLevel(otellog.SeverityError)
+	if len(errs) != 1 {
+		t.Fatalf("error logs = %v, want 1", len(errs))
+	}
+	if errs[0].TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("log trace id = %v, want %v", errs[0].TraceID(), span.SpanContext().TraceID())
+	}
+}
+
+func TestInitResetsState(t *testing.T) {
+	Init(func(cfg *config.Config) {
+		cfg.AppName = "first"
+		cfg.MaxSeries = 1
+	})
+	if err := metrics.Declare(metrics.Definition{Name: "declared", Kind: metrics.KindCounter}); err != nil {
+		t.Fatal(err)
+	}
+	log.SetLoggerLevel("noisy", zapcore.ErrorLevel)
+	metrics.EmitCount(context.Background(), "declared", 1)
+	log.CtxInfo(context.Background(), "first")
+
+	Init(func(cfg *config.Config) {
+		cfg.AppName = "second"
+	})
+	if config.Global.MaxSeries != 0 {
+		t.Errorf("config not reset: %+v", config.Global)
+	}
+	if len(log.LoggerLevels()) != 0 {
+		t.Errorf("logger levels not reset: %v", log.LoggerLevels())
+	}
+	for _, def := range metrics.Catalog() {
+		if def.Name == "declared" {
+			t.Errorf("declared metric survived Init")
+		}
+	}
+	// 声明状态已重置 未声明的指标不会被拒绝
+	metrics.EmitCount(context.Background(), "undeclared", 1)
+	AssertCounter(t, "undeclared", nil, 1)
+	if _, ok := FindMetric(t, "declared"); ok {
+		t.Errorf("metrics of previous test survived Init")
+	}
+	if got := len(Logs()); got != 0 {
+		t.Errorf("logs = %v, want 0", got)
+	}
+}
@@ -0,0 +1,76 @@
+package trace
+
+import (
+	"context"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 最多展示的进行中span数
+const maxActiveSpans = 1000
+
+// SpanInfo 进行中的span
+type SpanInfo struct {
+	Name     string    `json:"name"`
+	TraceID  string    `json:"trace_id"`
+	SpanID   string    `json:"span_id"`
+	ParentID string    `json:"parent_id,omitempty"`
+	Start    time.Time `json:"start"`
+	Elapsed  string    `json:"elapsed"`
+}
+
+var activeSpans sync.Map // trace.SpanID -> sdktrace.ReadOnlySpan
+
+// 记录进行中的span 用于调试
+type activeProcessor struct {
+}
+
+func (p *activeProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	activeSpans.Store(s.SpanContext().SpanID(), s)
+}
+
+func (p *activeProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	activeSpans.Delete(s.SpanContext().SpanID())
+}
+
+func (p *activeProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (p *activeProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// ActiveSpans 进行中的span 按开始时间排序
+func ActiveSpans() []SpanInfo {
+	var res []SpanInfo
+	activeSpans.Range(func(key, value any) bool {
+		s := value.(sdktrace.ReadOnlySpan)
+		info := SpanInfo{
+			Name:    s.Name(),
+			TraceID: s.SpanContext().TraceID().String(),
+			SpanID:  s.SpanContext().SpanID().String(),
+			Start:   s.StartTime(),
+			Elapsed: time.Since(s.StartTime()).String(),
+		}
+		if s.Parent().IsValid() {
+			info.ParentID = s.Parent().SpanID().String()
+		}
+		res = append(res, info)
+		return len(res) < maxActiveSpans
+	})
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Start.Before(res[j].Start)
+	})
+	return res
+}
+
+// Sampler 当前的采样设置
+func Sampler() string {
+	if sampler == nil {
+		return ""
+	}
+	return sampler.Description()
+}
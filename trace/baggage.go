@@ -0,0 +1,33 @@
+package trace
+
+import (
+	"context"
+	"github.com/watora/telemetry/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// 把ctx中baggage的指定key复制到span属性上
+type baggageProcessor struct {
+}
+
+func (p *baggageProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	bag := baggage.FromContext(ctx)
+	for _, key := range config.Global.BaggageKeys {
+		if member := bag.Member(key); member.Key() != "" {
+			s.SetAttributes(attribute.String(key, member.Value()))
+		}
+	}
+}
+
+func (p *baggageProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+}
+
+func (p *baggageProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (p *baggageProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
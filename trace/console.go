@@ -0,0 +1,124 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"github.com/watora/telemetry/internal/console"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 最多缓存的未结束trace数 超过后丢弃最早的
+const maxPendingTraces = 1000
+
+// 开发模式 trace的根span结束时在终端打印整棵span树
+type consoleProcessor struct {
+	w       io.Writer
+	mu      sync.Mutex
+	pending map[trace.TraceID][]sdktrace.ReadOnlySpan
+	order   []trace.TraceID
+}
+
+func newConsoleProcessor(w io.Writer) *consoleProcessor {
+	return &consoleProcessor{
+		w:       w,
+		pending: make(map[trace.TraceID][]sdktrace.ReadOnlySpan),
+	}
+}
+
+func (p *consoleProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+}
+
+func (p *consoleProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	traceID := s.SpanContext().TraceID()
+	p.mu.Lock()
+	spans, ok := p.pending[traceID]
+	if !ok {
+		p.order = append(p.order, traceID)
+		if len(p.order) > maxPendingTraces {
+			delete(p.pending, p.order[0])
+			p.order = p.order[1:]
+		}
+	}
+	spans = append(spans, s)
+	// 本地的根span结束 打印整棵树
+	if s.Parent().IsValid() && !s.Parent().IsRemote() {
+		p.pending[traceID] = spans
+		p.mu.Unlock()
+		return
+	}
+	delete(p.pending, traceID)
+	for i, id := range p.order {
+		if id == traceID {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+	p.mu.Unlock()
+	_, _ = io.WriteString(p.w, formatTree(s, spans))
+}
+
+func (p *consoleProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (p *consoleProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+func formatTree(root sdktrace.ReadOnlySpan, spans []sdktrace.ReadOnlySpan) string {
+	children := make(map[trace.SpanID][]sdktrace.ReadOnlySpan)
+	for _, s := range spans {
+		if s != root {
+			children[s.Parent().SpanID()] = append(children[s.Parent().SpanID()], s)
+		}
+	}
+	for _, c := range children {
+		sort.Slice(c, func(i, j int) bool {
+			return c[i].StartTime().Before(c[j].StartTime())
+		})
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%v %v %v\n", console.Bold("trace"), console.Dim(root.SpanContext().TraceID().String()),
+		duration(root))
+	var walk func(s sdktrace.ReadOnlySpan, prefix string, last bool)
+	walk = func(s sdktrace.ReadOnlySpan, prefix string, last bool) {
+		branch, next := "├─ ", "│  "
+		if last {
+			branch, next = "└─ ", "   "
+		}
+		name := console.Cyan(s.Name())
+		if s.Status().Code == codes.Error {
+			name = console.Red(s.Name() + " " + s.Status().Description)
+		}
+		fmt.Fprintf(b, "%v%v%v %v", prefix, branch, name, duration(s))
+		for _, kv := range s.Attributes() {
+			fmt.Fprintf(b, " %v", console.Dim(fmt.Sprintf("%v=%v", kv.Key, kv.Value.Emit())))
+		}
+		b.WriteString("\n")
+		c := children[s.SpanContext().SpanID()]
+		for i, child := range c {
+			walk(child, prefix+next, i == len(c)-1)
+		}
+	}
+	walk(root, "", true)
+	return b.String()
+}
+
+func duration(s sdktrace.ReadOnlySpan) string {
+	d := s.EndTime().Sub(s.StartTime())
+	text := d.Round(time.Microsecond).String()
+	switch {
+	case d > time.Second:
+		return console.Red(text)
+	case d > 100*time.Millisecond:
+		return console.Yellow(text)
+	}
+	return console.Green(text)
+}
@@ -0,0 +1,22 @@
+package trace
+
+import (
+	"context"
+	"github.com/watora/telemetry/internal/health"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// 记录导出结果
+// 启用落盘时失败的数据会落盘并返回成功 由spool.Interceptor按收集器实际的结果记录成功
+type healthExporter struct {
+	sdktrace.SpanExporter
+	spooled bool
+}
+
+func (e *healthExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if err != nil || !e.spooled {
+		health.Export(health.Spans, len(spans), err)
+	}
+	return err
+}
@@ -5,27 +5,72 @@ import (
 	"fmt"
 	"github.com/go-logr/stdr"
 	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/internal/health"
+	"github.com/watora/telemetry/internal/spool"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"os"
+	"path/filepath"
 )
 
 var tracer trace.Tracer
+var sampler sdktrace.Sampler
 
 func Init() {
 	stdr.SetVerbosity(5)
+	if config.Global.DevMode {
+		InitWithProcessor(newConsoleProcessor(os.Stderr))
+		return
+	}
 
-	exp, err := stdouttrace.New(stdouttrace.WithWriter(&noopWriter{}))
+	if config.Global.TraceEndPoint == "" {
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(&noopWriter{}))
+		if err != nil {
+			panic(fmt.Sprintf("init tracer err: %v", err))
+		}
+		InitWithProcessor(sdktrace.NewBatchSpanProcessor(&healthExporter{SpanExporter: exp}))
+		return
+	}
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithEndpoint(config.Global.TraceEndPoint),
+	}
+	if config.Global.SpoolDir != "" {
+		s, err := spool.New(filepath.Join(config.Global.SpoolDir, "spans"), config.SpoolMaxBytes())
+		if err != nil {
+			panic(fmt.Sprintf("init spool: %v", err))
+		}
+		health.SetQueue(health.Spans, func() (int64, int64) {
+			return int64(s.Len()), s.Dropped()
+		})
+		opts = append(opts, otlptracegrpc.WithDialOption(grpc.WithUnaryInterceptor(spoolInterceptor(s))))
+	}
+	exp, err := otlptracegrpc.New(context.Background(), opts...)
 	if err != nil {
 		panic(fmt.Sprintf("init tracer err: %v", err))
 	}
-	processor := sdktrace.NewBatchSpanProcessor(exp)
+	InitWithProcessor(sdktrace.NewBatchSpanProcessor(&healthExporter{SpanExporter: exp, spooled: config.Global.SpoolDir != ""}))
+}
+
+// InitWithProcessor 使用指定的processor初始化 如测试中使用内存exporter
+func InitWithProcessor(processor sdktrace.SpanProcessor) {
+	sampler = sdktrace.AlwaysSample()
 	provider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithSpanProcessor(&baggageProcessor{}),
+		sdktrace.WithSpanProcessor(&activeProcessor{}),
+		sdktrace.WithSpanProcessor(&redactProcessor{processor}),
 	)
 	otel.SetTracerProvider(provider)
+	// 跨服务传递trace和baggage 应用已经设置了propagator时保留 默认的propagator不处理任何字段
+	if len(otel.GetTextMapPropagator().Fields()) == 0 {
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	}
 
 	tracer = provider.Tracer(config.Global.AppName)
 }
@@ -0,0 +1,26 @@
+package trace
+
+import (
+	"github.com/watora/telemetry/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"reflect"
+	"testing"
+)
+
+// 应用自己设置的propagator不被覆盖
+func TestInitKeepsAppPropagator(t *testing.T) {
+	old := otel.GetTextMapPropagator()
+	defer otel.SetTextMapPropagator(old)
+	otel.SetTextMapPropagator(propagation.Baggage{})
+
+	oldCfg := *config.Global
+	defer func() { *config.Global = oldCfg }()
+	*config.Global = config.Config{AppName: "propagator"}
+	InitWithProcessor(sdktrace.NewSimpleSpanProcessor(tracetest.NewInMemoryExporter()))
+	if got := otel.GetTextMapPropagator().Fields(); !reflect.DeepEqual(got, []string{"baggage"}) {
+		t.Errorf("propagator fields = %v, want [baggage]", got)
+	}
+}
@@ -0,0 +1,58 @@
+package trace
+
+import (
+	"context"
+	"github.com/watora/telemetry/internal/redact"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// 在span结束交给下游processor前对属性和事件属性脱敏
+type redactProcessor struct {
+	next sdktrace.SpanProcessor
+}
+
+func (p *redactProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+func (p *redactProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	r := redact.Current()
+	if r == nil {
+		p.next.OnEnd(s)
+		return
+	}
+	events := s.Events()
+	redactedEvents := make([]sdktrace.Event, 0, len(events))
+	for _, event := range events {
+		event.Attributes = r.Attributes(event.Attributes)
+		redactedEvents = append(redactedEvents, event)
+	}
+	p.next.OnEnd(&redactedSpan{
+		ReadOnlySpan: s,
+		attributes:   r.Attributes(s.Attributes()),
+		events:       redactedEvents,
+	})
+}
+
+func (p *redactProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *redactProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	attributes []attribute.KeyValue
+	events     []sdktrace.Event
+}
+
+func (s *redactedSpan) Attributes() []attribute.KeyValue {
+	return s.attributes
+}
+
+func (s *redactedSpan) Events() []sdktrace.Event {
+	return s.events
+}
@@ -0,0 +1,29 @@
+package trace
+
+import (
+	"github.com/watora/telemetry/internal/health"
+	"github.com/watora/telemetry/internal/spool"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// 导出失败时把请求落盘 恢复后先按顺序重放
+func spoolInterceptor(s *spool.Spool) grpc.UnaryClientInterceptor {
+	return spool.Interceptor(s, health.Spans, requestSpans)
+}
+
+// 请求中的span数 和healthExporter的计数一致
+func requestSpans(msg proto.Message) int {
+	req, ok := msg.(*coltracepb.ExportTraceServiceRequest)
+	if !ok {
+		return 0
+	}
+	n := 0
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			n += len(ss.GetSpans())
+		}
+	}
+	return n
+}
@@ -0,0 +1,105 @@
+package trace
+
+import (
+	"context"
+	"github.com/watora/telemetry/config"
+	"github.com/watora/telemetry/internal/health"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net"
+	"sync"
+	"testing"
+)
+
+// 记录收到的span名 down时返回Unavailable
+type stubCollector struct {
+	coltracepb.UnimplementedTraceServiceServer
+	mu    sync.Mutex
+	down  bool
+	names []string
+}
+
+func (c *stubCollector) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down {
+		return nil, status.Error(codes.Unavailable, "down")
+	}
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				c.names = append(c.names, span.GetName())
+			}
+		}
+	}
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func (c *stubCollector) setDown(down bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.down = down
+}
+
+func (c *stubCollector) received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.names...)
+}
+
+func TestInitSpoolsSpans(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := &stubCollector{down: true}
+	srv := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(srv, collector)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	old := *config.Global
+	defer func() { *config.Global = old }()
+	*config.Global = config.Config{AppName: "spool", TraceEndPoint: lis.Addr().String(), SpoolDir: t.TempDir()}
+	Init()
+	provider := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+	defer provider.Shutdown(context.Background())
+	flush := func(name string) {
+		_, span := StartTrace(context.Background(), name)
+		span.End()
+		if err := provider.ForceFlush(context.Background()); err != nil {
+			t.Fatalf("flush %v: %v", name, err)
+		}
+	}
+	before := health.Snapshot()[health.Spans]
+
+	flush("first")
+	flush("second")
+	status := health.Snapshot()[health.Spans]
+	if status.QueueSize != 2 || status.Spooled-before.Spooled != 2 || status.Exported != before.Exported {
+		t.Errorf("status while collector down = %+v", status)
+	}
+
+	// 恢复后先重放落盘的span
+	collector.setDown(false)
+	flush("third")
+	want := []string{"first", "second", "third"}
+	got := collector.received()
+	if len(got) != len(want) {
+		t.Fatalf("collector received %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("collector received %v, want %v", got, want)
+			break
+		}
+	}
+	status = health.Snapshot()[health.Spans]
+	if status.QueueSize != 0 || status.Exported-before.Exported != 3 {
+		t.Errorf("status after recovery = %+v", status)
+	}
+}